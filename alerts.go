@@ -0,0 +1,96 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GitHub alerts are blockquotes starting with a "[!NOTE]" style marker line.
+// processAlerts replaces the marker with alertMarker followed by the alert
+// type, which survives rendering as plain text. applyTheme then turns that
+// line into the title of the callout and colors the quote bar.
+
+// alertMarker is a private use character that never appears in documents
+const alertMarker = "\uE000"
+
+// alertType describes one kind of GitHub alert
+type alertType struct {
+	name  string
+	title string
+	icon  string
+	color func(c *ColorConfig) string
+}
+
+var alertTypes = []alertType{
+	{"NOTE", "Note", "ℹ", func(c *ColorConfig) string { return c.AlertNote }},
+	{"TIP", "Tip", "💡", func(c *ColorConfig) string { return c.AlertTip }},
+	{"IMPORTANT", "Important", "❗", func(c *ColorConfig) string { return c.AlertImportant }},
+	{"WARNING", "Warning", "⚠", func(c *ColorConfig) string { return c.AlertWarning }},
+	{"CAUTION", "Caution", "🛑", func(c *ColorConfig) string { return c.AlertCaution }},
+}
+
+var (
+	alertLinePattern  = regexp.MustCompile(`(?i)^( {0,3}(?:> ?)+)\[!(NOTE|TIP|IMPORTANT|WARNING|CAUTION)\]\s*$`)
+	quoteLinePattern  = regexp.MustCompile(`^ {0,3}>`)
+	codeFencePattern  = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+	alertTitlePattern = regexp.MustCompile(`^` + alertMarker + `([A-Z]+)`)
+)
+
+// codeFences follows the fenced code blocks of markdown, fed its lines in
+// order
+type codeFences struct {
+	// open is the fence of the code block around the current line
+	open string
+}
+
+// inCode tells whether line opens, closes or lies in a fenced code block. A
+// block is closed by a fence of the same character, at least as long as the
+// opening one and with nothing after it
+func (f *codeFences) inCode(line string) bool {
+	match := codeFencePattern.FindStringSubmatch(line)
+	switch {
+	case match == nil:
+		return f.open != ""
+	case f.open == "":
+		f.open = match[1]
+	case match[1][0] == f.open[0] && len(match[1]) >= len(f.open) && strings.TrimSpace(match[2]) == "":
+		f.open = ""
+	}
+	return true
+}
+
+// processAlerts marks the first line of GitHub alert blockquotes so that
+// they can be rendered as callouts
+func processAlerts(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	var fences codeFences
+	for i, line := range lines {
+		if fences.inCode(line) {
+			continue
+		}
+
+		// The marker must open the blockquote
+		if i > 0 && quoteLinePattern.MatchString(lines[i-1]) {
+			continue
+		}
+		if match := alertLinePattern.FindStringSubmatch(line); match != nil {
+			lines[i] = match[1] + alertMarker + strings.ToUpper(match[2])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// findAlertType returns the index in alertTypes of the alert title starting
+// body, or -1
+func findAlertType(body string) int {
+	match := alertTitlePattern.FindStringSubmatch(body)
+	if match == nil {
+		return -1
+	}
+	for i, alert := range alertTypes {
+		if alert.name == match[1] {
+			return i
+		}
+	}
+	return -1
+}
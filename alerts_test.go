@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessAlerts(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"note", "> [!NOTE]\n> Text", "> " + alertMarker + "NOTE\n> Text"},
+		{"lower case", "> [!warning]  \n> Text", "> " + alertMarker + "WARNING\n> Text"},
+		{"nested quote", "> > [!TIP]\n> > Text", "> > " + alertMarker + "TIP\n> > Text"},
+		{"unknown type", "> [!DANGER]\n> Text", "> [!DANGER]\n> Text"},
+		{"not first line", "> Text\n> [!NOTE]", "> Text\n> [!NOTE]"},
+		{"inline marker", "> [!NOTE] Text", "> [!NOTE] Text"},
+		{"code block", "```\n> [!NOTE]\n```", "```\n> [!NOTE]\n```"},
+		{"other fence in code block", "~~~\n```\n> [!NOTE]\n~~~\n> [!TIP]", "~~~\n```\n> [!NOTE]\n~~~\n> " + alertMarker + "TIP"},
+		{"nested fences", "````\n```\n> [!NOTE]\n```\n> [!NOTE]\n````\n> [!TIP]", "````\n```\n> [!NOTE]\n```\n> [!NOTE]\n````\n> " + alertMarker + "TIP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processAlerts(tt.input); got != tt.expected {
+				t.Errorf("processAlerts() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCodeFences(t *testing.T) {
+	lines := []string{"text", "````md", "```go", "code", "```", "~~~~", "````` x", "`````", "text", "~~~", "```", "~~~~", "text"}
+	expected := []bool{false, true, true, true, true, true, true, true, false, true, true, true, false}
+
+	var fences codeFences
+	for i, line := range lines {
+		if got := fences.inCode(line); got != expected[i] {
+			t.Errorf("inCode(%q) = %v, expected %v", line, got, expected[i])
+		}
+	}
+}
+
+func TestRenderAlerts(t *testing.T) {
+	useColorProfile(t, ProfileANSI256)
+
+	for _, alert := range alertTypes {
+		t.Run(alert.name, func(t *testing.T) {
+			raw := "> [!" + alert.name + "]\n> Some text.\n\nAfter.\n"
+			render := func(config *Config) []byte {
+				m := model{raw: raw, width: 80, config: config}
+				return m.render()
+			}
+
+			config := DefaultConfig()
+			rendered := render(config)
+			plain := stripANSI(string(rendered))
+			if !strings.Contains(plain, "┃ "+alert.icon+" "+alert.title) {
+				t.Errorf("Expected the %s title, got %q", alert.title, plain)
+			}
+			if strings.Contains(plain, "[!") || strings.Contains(plain, alertMarker) {
+				t.Errorf("Expected the marker to be replaced, got %q", plain)
+			}
+
+			// The alert color applies to the bar of every line of the alert
+			changed := DefaultConfig()
+			setColorKey(t, &changed.Colors, "alert_"+strings.ToLower(alert.name), "#5f005f")
+			if bytes.Equal(render(changed), rendered) {
+				t.Errorf("changing the alert color has no effect on the rendered document")
+			}
+			if got := strings.Count(string(render(changed)), "\x1b[38;5;53m┃"); got != 2 {
+				t.Errorf("Expected 2 bars in the alert color, got %d", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backgroundQueryTimeout bounds how long bleamd waits for the terminal to
+// answer the background color query
+const backgroundQueryTimeout = 300 * time.Millisecond
+
+// terminalBackground describes the background color of the terminal
+type terminalBackground struct {
+	// color is the background as #rrggbb, empty if it couldn't be detected
+	color string
+	// light is set for light backgrounds
+	light bool
+	// source tells how the background was found: "OSC 11", "COLORFGBG" or
+	// "default" when nothing answered and a dark background is assumed
+	source string
+}
+
+// String describes the background for --config-path
+func (b terminalBackground) String() string {
+	kind := "dark"
+	if b.light {
+		kind = "light"
+	}
+	if b.color == "" {
+		return fmt.Sprintf("%s (%s)", kind, b.source)
+	}
+	return fmt.Sprintf("%s (%s, from %s)", kind, b.color, b.source)
+}
+
+// detectedBackground caches the result of the first background query
+var detectedBackground *terminalBackground
+
+// currentBackground returns the terminal background, querying it on first use
+func currentBackground() terminalBackground {
+	if detectedBackground == nil {
+		bg := detectBackground()
+		detectedBackground = &bg
+	}
+	return *detectedBackground
+}
+
+// detectBackground asks the terminal for its background color with OSC 11,
+// falling back to $COLORFGBG and then to a dark background
+func detectBackground() terminalBackground {
+	if reply, err := queryBackgroundColor(backgroundQueryTimeout); err == nil {
+		if hex, err := parseOSC11Reply(reply); err == nil {
+			if bg, err := backgroundFromHex(hex, "OSC 11"); err == nil {
+				return bg
+			}
+		}
+	}
+
+	if bg, ok := backgroundFromColorFGBG(os.Getenv("COLORFGBG")); ok {
+		return bg
+	}
+
+	return terminalBackground{source: "default"}
+}
+
+// osc11ReplyPattern matches the answer to an OSC 11 query, for example
+// "\x1b]11;rgb:ffff/ffff/ffff\x1b\\". Each channel has 1 to 4 hex digits.
+var osc11ReplyPattern = regexp.MustCompile(`\x1b\]11;rgb:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`)
+
+// parseOSC11Reply extracts the background color from the terminal reply
+func parseOSC11Reply(reply []byte) (string, error) {
+	sub := osc11ReplyPattern.FindSubmatch(reply)
+	if sub == nil {
+		return "", fmt.Errorf("no background color in terminal reply %q", reply)
+	}
+
+	var channels [3]int
+	for i, digits := range sub[1:] {
+		v, err := strconv.ParseUint(string(digits), 16, 16)
+		if err != nil {
+			return "", err
+		}
+		// Scale to 8 bits whatever the number of digits
+		maxValue := uint64(1)<<(4*len(digits)) - 1
+		channels[i] = int(v * 255 / maxValue)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channels[0], channels[1], channels[2]), nil
+}
+
+// backgroundFromHex classifies a #rrggbb background by its perceived brightness
+func backgroundFromHex(hex, source string) (terminalBackground, error) {
+	c, err := parseHexColor(hex)
+	if err != nil {
+		return terminalBackground{}, err
+	}
+	return terminalBackground{
+		color:  c.hex(),
+		light:  c.luminance() >= 128,
+		source: source,
+	}, nil
+}
+
+// backgroundFromColorFGBG reads the background from a "fg;bg" value as set
+// by rxvt, konsole and others
+func backgroundFromColorFGBG(value string) (terminalBackground, bool) {
+	parts := strings.Split(value, ";")
+	if len(parts) < 2 {
+		return terminalBackground{}, false
+	}
+
+	index, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || index < 0 || index >= len(ansi16Palette) {
+		return terminalBackground{}, false
+	}
+
+	bg, err := backgroundFromHex(ansi16Palette[index].hex(), "COLORFGBG")
+	return bg, err == nil
+}
+
+// luminance returns the perceived brightness of the color, from 0 to 255
+func (c rgbColor) luminance() int {
+	return (299*c.r + 587*c.g + 114*c.b) / 1000
+}
+
+// selectTheme picks the theme matching the background. light_theme and
+// dark_theme take precedence over theme, which stays the fallback.
+func selectTheme(theme, lightTheme, darkTheme string, bg terminalBackground) (name, source string) {
+	if bg.light && lightTheme != "" {
+		return lightTheme, "light_theme"
+	}
+	if !bg.light && darkTheme != "" {
+		return darkTheme, "dark_theme"
+	}
+	if theme != "" {
+		return theme, "theme"
+	}
+	return "", ""
+}
@@ -0,0 +1,24 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// queryBackgroundColor is not supported on this platform, the background is
+// taken from $COLORFGBG instead
+func queryBackgroundColor(timeout time.Duration) ([]byte, error) {
+	return nil, errors.New("background color query not supported")
+}
+
+// queryTerminal is not supported on this platform
+func queryTerminal(request string, timeout time.Duration) ([]byte, error) {
+	return nil, errors.New("terminal queries not supported")
+}
+
+// cellSize returns a common size of terminal cells in pixels
+func cellSize() (int, int) {
+	return defaultCellWidth, defaultCellHeight
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// useBackground pretends the terminal has the given background for the
+// duration of the test
+func useBackground(t *testing.T, bg terminalBackground) {
+	t.Helper()
+	previous := detectedBackground
+	detectedBackground = &bg
+	t.Cleanup(func() { detectedBackground = previous })
+}
+
+func TestParseOSC11Reply(t *testing.T) {
+	tests := []struct {
+		reply    string
+		expected string
+		wantErr  bool
+	}{
+		{"\x1b]11;rgb:ffff/ffff/ffff\x1b\\\x1b[12;1R", "#ffffff", false},
+		{"\x1b]11;rgb:2828/2c2c/3434\x07", "#282c34", false},
+		{"\x1b]11;rgb:fd/f6/e3\x1b\\", "#fdf6e3", false},
+		{"\x1b]11;rgb:f/0/8\x1b\\", "#ff0088", false},
+		{"\x1b[12;1R", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOSC11Reply([]byte(tt.reply))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseOSC11Reply(%q) error = %v, wantErr %v", tt.reply, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("parseOSC11Reply(%q) = %q, expected %q", tt.reply, got, tt.expected)
+		}
+	}
+}
+
+func TestBackgroundFromColorFGBG(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantOK    bool
+		wantLight bool
+	}{
+		{"15;0", true, false},
+		{"0;15", true, true},
+		{"0;default;7", true, true},
+		{"", false, false},
+		{"15", false, false},
+		{"15;default", false, false},
+	}
+
+	for _, tt := range tests {
+		bg, ok := backgroundFromColorFGBG(tt.value)
+		if ok != tt.wantOK || bg.light != tt.wantLight {
+			t.Errorf("backgroundFromColorFGBG(%q) = %+v, %v, expected light=%v ok=%v", tt.value, bg, ok, tt.wantLight, tt.wantOK)
+		}
+	}
+}
+
+func TestSelectTheme(t *testing.T) {
+	light := terminalBackground{light: true}
+	dark := terminalBackground{}
+
+	tests := []struct {
+		theme, lightTheme, darkTheme string
+		bg                           terminalBackground
+		expected, source             string
+	}{
+		{"", "catppuccin-latte", "one-dark", light, "catppuccin-latte", "light_theme"},
+		{"", "catppuccin-latte", "one-dark", dark, "one-dark", "dark_theme"},
+		{"dracula", "catppuccin-latte", "", dark, "dracula", "theme"},
+		{"", "catppuccin-latte", "", dark, "", ""},
+	}
+
+	for _, tt := range tests {
+		name, source := selectTheme(tt.theme, tt.lightTheme, tt.darkTheme, tt.bg)
+		if name != tt.expected || source != tt.source {
+			t.Errorf("selectTheme(%q, %q, %q, light=%v) = %q, %q, expected %q, %q",
+				tt.theme, tt.lightTheme, tt.darkTheme, tt.bg.light, name, source, tt.expected, tt.source)
+		}
+	}
+}
+
+func TestGeneratedConfigPicksThemeFromBackground(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := saveStarterConfig(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a light and a dark theme to the generated config
+	var file map[string]interface{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file["light_theme"] = "catppuccin-latte"
+	file["dark_theme"] = "dracula"
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		bg       terminalBackground
+		expected string
+	}{
+		{terminalBackground{color: "#ffffff", light: true, source: "OSC 11"}, "catppuccin-latte"},
+		{terminalBackground{color: "#000000", source: "OSC 11"}, "dracula"},
+	} {
+		useBackground(t, tt.bg)
+		config, err := parseConfig(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		theme, _ := LoadTheme(tt.expected)
+		if config.Colors != theme.Colors {
+			t.Errorf("Expected the colors of %s, got heading1 %q instead of %q", tt.expected, config.Colors.Heading1, theme.Colors.Heading1)
+		}
+	}
+}
+
+func TestParseConfigPicksThemeFromBackground(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	data := []byte(`{
+		"light_theme": "catppuccin-latte",
+		"dark_theme": "dracula",
+		"colors": {"heading2": "#010203"}
+	}`)
+
+	useBackground(t, terminalBackground{color: "#ffffff", light: true, source: "OSC 11"})
+	config, err := parseConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latte, _ := LoadTheme("catppuccin-latte")
+	if config.Theme != "catppuccin-latte" || config.themeSource != "light_theme" {
+		t.Errorf("Expected catppuccin-latte from light_theme, got %q from %q", config.Theme, config.themeSource)
+	}
+	if config.Colors.Heading1 != latte.Colors.Heading1 {
+		t.Errorf("Expected the light theme colors, got heading1 %q", config.Colors.Heading1)
+	}
+	if config.Colors.Heading2 != "#010203" {
+		t.Errorf("Expected user colors layered on top, got heading2 %q", config.Colors.Heading2)
+	}
+
+	useBackground(t, terminalBackground{source: "default"})
+	config, err = parseConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Theme != "dracula" || config.themeSource != "dark_theme" {
+		t.Errorf("Expected dracula from dark_theme, got %q from %q", config.Theme, config.themeSource)
+	}
+}
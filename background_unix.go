@@ -0,0 +1,118 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/sys/unix"
+)
+
+// cursorReplyPattern matches the answer to a cursor position request
+var cursorReplyPattern = regexp.MustCompile(`\x1b\[\d+;\d+R`)
+
+// queryBackgroundColor sends an OSC 11 query to the controlling terminal and
+// returns its raw reply
+func queryBackgroundColor(timeout time.Duration) ([]byte, error) {
+	return queryTerminal("\x1b]11;?\x1b\\", timeout)
+}
+
+// queryTerminal sends request to the controlling terminal and returns its raw
+// reply
+func queryTerminal(request string, timeout time.Duration) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	fd := tty.Fd()
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(fd, state) //nolint:errcheck
+
+	// Every terminal answers the cursor position request, which tells us when
+	// to stop reading if the request is not supported
+	if _, err := tty.WriteString(request + "\x1b[6n"); err != nil {
+		return nil, err
+	}
+
+	var reply []byte
+	deadline := time.Now().Add(timeout)
+	for !cursorReplyPattern.Match(reply) {
+		chunk, err := readTerminal(tty, time.Until(deadline))
+		if err != nil {
+			drainTerminal(tty, reply)
+			return nil, err
+		}
+		reply = append(reply, chunk...)
+	}
+
+	return reply, nil
+}
+
+// drainTimeout bounds the wait for the rest of a reply that came too late
+const drainTimeout = time.Second
+
+// drainTerminal reads what is left of a late reply, up to the cursor
+// position that ends it, before the terminal mode is restored. The UI
+// would otherwise read the rest of the reply as keypresses.
+func drainTerminal(tty *os.File, reply []byte) {
+	deadline := time.Now().Add(drainTimeout)
+	for !cursorReplyPattern.Match(reply) {
+		chunk, err := readTerminal(tty, time.Until(deadline))
+		if err != nil {
+			return
+		}
+		reply = append(reply, chunk...)
+	}
+}
+
+// readTerminal returns the bytes the terminal sends within wait
+func readTerminal(tty *os.File, wait time.Duration) ([]byte, error) {
+	fd := int(tty.Fd())
+	for {
+		if wait <= 0 {
+			return nil, errors.New("timeout waiting for the terminal")
+		}
+		start := time.Now()
+
+		var readable unix.FdSet
+		readable.Set(fd)
+		tv := unix.NsecToTimeval(wait.Nanoseconds())
+		n, err := unix.Select(fd+1, &readable, nil, nil, &tv)
+		if err == unix.EINTR {
+			wait -= time.Since(start)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, errors.New("timeout waiting for the terminal")
+		}
+
+		buf := make([]byte, 64)
+		n, err = tty.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// cellSize returns the size of a terminal cell in pixels, as reported by the
+// terminal, or a common size when it doesn't tell
+func cellSize() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 || ws.Xpixel == 0 || ws.Ypixel == 0 {
+		return defaultCellWidth, defaultCellHeight
+	}
+	return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row)
+}
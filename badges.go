@@ -5,24 +5,32 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
 )
 
-// processBadges converts shields.io badge images into text representations
+// badgeMarker fills the runs standing for badge pills. go-term-markdown
+// would wrap lines inside the pills, so the markdown holds runs as wide as
+// the pills and insertBadges draws the pills once the lines are wrapped.
+const badgeMarker = "\uE008"
+
+var badgeRunPattern = runPattern(badgeMarker)
+
+// processBadges converts badge images into text representations
 // This allows badges to be displayed in the terminal similar to how Grip displays them
-func processBadges(markdown string, config *Config) string {
-	// Match shields.io badge images in markdown format
+// The pills are returned in the order of their runs
+func processBadges(markdown string, config *Config) (string, []string) {
+	// Match badge images in markdown format
 	// Pattern: [![alt text](https://img.shields.io/...)](optional-link)
 	
-	// First, find standalone badges: ![alt](shield-url)
-	// Second, find linked badges: [![alt](shield-url)](link-url)
+	// First, find standalone badges: ![alt](badge-url)
+	// Second, find linked badges: [![alt](badge-url)](link-url)
+	// Images whose URL no provider recognizes are left alone
 	
 	result := markdown
-	
-	// Pattern for linked badges: [![alt](badge-url)](link)
-	linkedBadgePattern := regexp.MustCompile(`\[!\[[^\]]*\]\((https://img\.shields\.io/[^)]+)\)\]\(([^)]+)\)`)
-	
-	// Pattern for standalone badges: ![alt](badge-url)
-	standaloneBadgePattern := regexp.MustCompile(`!\[[^\]]*\]\((https://img\.shields\.io/[^)]+)\)`)
+	var pills []string
 	
 	// Process linked badges first (to avoid matching them as standalone)
 	linkedMatches := linkedBadgePattern.FindAllStringSubmatchIndex(result, -1)
@@ -41,11 +49,14 @@ func processBadges(markdown string, config *Config) string {
 		linkURL := result[linkURLStart:linkURLEnd]
 		
 		// Parse the badge to extract label and message
-		label, message, color := parseShieldsBadge(badgeURL)
+		label, message, color, ok := parseBadge(badgeURL)
+		if !ok {
+			continue
+		}
 		
 		// Create a text representation as a clickable link
 		// Format: [label: message](link)
-		textBadge := fmt.Sprintf("[%s](%s)", formatBadgeText(label, message, color, config), linkURL)
+		textBadge := fmt.Sprintf("[%s](%s)", badgeRun(formatBadgeText(label, message, color, parseBadgeStyle(badgeURL), config), &pills), linkURL)
 		
 		result = result[:matchStart] + textBadge + result[matchEnd:]
 	}
@@ -63,16 +74,190 @@ func processBadges(markdown string, config *Config) string {
 		badgeURL := result[badgeURLStart:badgeURLEnd]
 		
 		// Parse the badge to extract label and message
-		label, message, color := parseShieldsBadge(badgeURL)
+		label, message, color, ok := parseBadge(badgeURL)
+		if !ok {
+			continue
+		}
 		
 		// Create a text representation
 		// Format: [label: message]
-		textBadge := formatBadgeText(label, message, color, config)
+		textBadge := badgeRun(formatBadgeText(label, message, color, parseBadgeStyle(badgeURL), config), &pills)
 		
 		result = result[:matchStart] + textBadge + result[matchEnd:]
 	}
 	
-	return result
+	return result, pills
+}
+
+// badgeRun returns the run standing for pill in the markdown, adding pill to
+// pills
+func badgeRun(pill string, pills *[]string) string {
+	run, ok := placeholderRun(badgeMarker, len(*pills), runewidth.StringWidth(stripANSI(pill)))
+	if !ok {
+		return markdownEscape(stripANSI(pill))
+	}
+	*pills = append(*pills, pill)
+	return run
+}
+
+// insertBadges replaces the runs standing for badges in rendered with their
+// pills
+func insertBadges(rendered []byte, pills []string) []byte {
+	return replaceRuns(rendered, badgeRunPattern, pills)
+}
+
+var (
+	// Pattern for linked badges: [![alt](badge-url)](link)
+	linkedBadgePattern = regexp.MustCompile(`\[!\[[^\]]*\]\((https?://[^)\s]+)\)\]\(([^)]+)\)`)
+	
+	// Pattern for standalone badges: ![alt](badge-url)
+	standaloneBadgePattern = regexp.MustCompile(`!\[[^\]]*\]\((https?://[^)\s]+)\)`)
+)
+
+// registryNames are the labels of package registry badges, by the name
+// shields.io and badgen.net give the registries in their paths
+var registryNames = map[string]string{
+	"npm":    "npm",
+	"pypi":   "pypi",
+	"crates": "crates.io",
+}
+
+// furyRegistries maps the paths of badge.fury.io to registry names
+var furyRegistries = map[string]string{
+	"js": "npm",
+	"py": "pypi",
+	"rs": "crates",
+}
+
+// isBadgeURL tells whether url is the image of a badge processBadges draws
+func isBadgeURL(url string) bool {
+	_, _, _, ok := parseBadge(url)
+	return ok
+}
+
+// parseBadge extracts label, message, and color from the URL of a badge
+// image, ok being false when the URL isn't a badge of a known provider
+func parseBadge(badgeURL string) (label, message, color string, ok bool) {
+	parsedURL, err := url.Parse(badgeURL)
+	if err != nil {
+		return "", "", "", false
+	}
+	path := strings.Trim(parsedURL.Path, "/")
+	query := parsedURL.Query()
+	
+	switch strings.TrimPrefix(parsedURL.Host, "www.") {
+	case "img.shields.io":
+		label, message, color = parseShieldsBadge(badgeURL)
+		return label, message, color, true
+		
+	case "badgen.net":
+		label, message, color = parseBadgenBadge(path, query)
+		return label, message, color, true
+		
+	case "github.com":
+		// Workflow badges: /<user>/<repo>/actions/workflows/<file>/badge.svg,
+		// or /<user>/<repo>/workflows/<name>/badge.svg for older ones
+		parts := strings.Split(path, "/")
+		if len(parts) == 6 && parts[2] == "actions" && parts[3] == "workflows" && parts[5] == "badge.svg" {
+			label, message = workflowBadge(parts[4], query)
+			return label, message, "", true
+		}
+		if len(parts) == 5 && parts[2] == "workflows" && parts[4] == "badge.svg" {
+			label, message = workflowBadge(parts[3], query)
+			return label, message, "", true
+		}
+		
+	case "codecov.io":
+		// /gh/<user>/<repo>[/branch/<branch>]/graph/badge.svg
+		parts := strings.Split(path, "/")
+		if len(parts) >= 5 && parts[len(parts)-1] == "badge.svg" {
+			return "codecov", parts[1] + "/" + parts[2], "#f01f7a", true
+		}
+		
+	case "goreportcard.com":
+		// /badge/<module>
+		if module := strings.TrimPrefix(path, "badge/"); module != path && module != "" {
+			return "go report", strings.TrimPrefix(module, "github.com/"), "#00add8", true
+		}
+		
+	case "pkg.go.dev":
+		// /badge/<module>.svg
+		if module := strings.TrimSuffix(strings.TrimPrefix(path, "badge/"), ".svg"); module != path && module != "" {
+			return "go reference", strings.TrimPrefix(module, "github.com/"), "#007d9c", true
+		}
+		
+	case "badge.fury.io":
+		// /<js|py|rb|...>/<package>.svg
+		parts := strings.SplitN(strings.TrimSuffix(path, ".svg"), "/", 2)
+		if len(parts) == 2 {
+			if name, known := registryNames[furyRegistries[parts[0]]]; known {
+				return name, parts[1], "blue", true
+			}
+			return parts[0], parts[1], "blue", true
+		}
+	}
+	
+	return "", "", "", false
+}
+
+// workflowBadge returns the label and message of a GitHub Actions workflow
+// badge: the workflow name, and the branch it reports on
+func workflowBadge(workflow string, query url.Values) (label, message string) {
+	label = strings.TrimSuffix(strings.TrimSuffix(workflow, ".yml"), ".yaml")
+	message = query.Get("branch")
+	if message == "" {
+		message = "workflow"
+	}
+	return
+}
+
+// registryBadge returns the label and message of a package version badge,
+// from a "<registry>/v/<package>" path
+func registryBadge(path string) (label, message string, ok bool) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 3 || parts[1] != "v" {
+		return "", "", false
+	}
+	name, known := registryNames[parts[0]]
+	if !known {
+		return "", "", false
+	}
+	return name, strings.TrimSuffix(parts[2], ".svg"), true
+}
+
+// parseBadgenBadge extracts label, message, and color from the path of a
+// badgen.net badge
+func parseBadgenBadge(path string, query url.Values) (label, message, color string) {
+	parts := strings.Split(path, "/")
+	switch {
+	case parts[0] == "badge" && len(parts) >= 3:
+		// Static badge: /badge/<label>/<message>/<color>
+		label, message = parts[1], parts[2]
+		if len(parts) >= 4 {
+			color = parts[3]
+		}
+	case len(parts) >= 4 && parts[0] == "github" && (parts[1] == "license" || parts[1] == "stars"):
+		label, message = parts[1], parts[2]+"/"+parts[3]
+		color = "blue"
+		if parts[1] == "stars" {
+			color = "yellow"
+		}
+	default:
+		if name, pkg, ok := registryBadge(path); ok {
+			label, message, color = name, pkg, "blue"
+		} else {
+			// Other badges: /<provider>/<topic>..., shown as the provider and
+			// the rest of the path
+			label, message = parts[0], strings.Join(parts[1:], "/")
+		}
+	}
+	if query.Get("label") != "" {
+		label = query.Get("label")
+	}
+	if query.Get("color") != "" {
+		color = query.Get("color")
+	}
+	return
 }
 
 // parseShieldsBadge extracts label, message, and color from a shields.io badge URL
@@ -91,6 +276,10 @@ func parseShieldsBadge(badgeURL string) (label, message, color string) {
 	// 1. /badge/<label>-<message>-<color>
 	// 2. /github/license/<user>/<repo>
 	// 3. /github/stars/<user>/<repo>
+	// 4. /github/actions/workflow/status/<user>/<repo>/<file>
+	// 5. /<npm|pypi|crates>/v/<package>
+	// 6. /codecov/c/<github|gh>/<user>/<repo>
+	// 7. /endpoint?url=<json>
 	
 	// Check if it's a GitHub-specific badge
 	if strings.HasPrefix(path, "github/license/") {
@@ -156,17 +345,82 @@ func parseShieldsBadge(badgeURL string) (label, message, color string) {
 		label = urlDecode(label)
 		message = urlDecode(message)
 		
+		// The query overrides the path
+		if query.Get("label") != "" {
+			label = query.Get("label")
+		}
+		if query.Get("color") != "" {
+			color = query.Get("color")
+		}
+		
+		return
+	}
+	
+	if strings.HasPrefix(path, "github/actions/workflow/status/") {
+		parts := strings.Split(path, "/")
+		if len(parts) >= 7 {
+			label, message = workflowBadge(parts[6], query)
+			if query.Get("label") != "" {
+				label = query.Get("label")
+			}
+			color = query.Get("color")
+			return
+		}
+	}
+	
+	if name, pkg, ok := registryBadge(path); ok {
+		label = name
+		message = pkg
+		color = query.Get("color")
+		if color == "" {
+			color = "blue"
+		}
+		return
+	}
+	
+	if strings.HasPrefix(path, "codecov/c/") {
+		parts := strings.Split(path, "/")
+		if len(parts) >= 5 {
+			label = "coverage"
+			message = fmt.Sprintf("%s/%s", parts[3], parts[4])
+			color = query.Get("color")
+			if color == "" {
+				color = "#f01f7a"
+			}
+			return
+		}
+	}
+	
+	// Endpoint badges describe themselves in a JSON file, named after them
+	if path == "endpoint" {
+		label = query.Get("label")
+		if endpoint, err := url.Parse(query.Get("url")); err == nil && endpoint.Host != "" {
+			if label == "" {
+				name := strings.TrimRight(endpoint.Path, "/")
+				label = strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], ".json")
+			}
+			message = endpoint.Host
+		}
+		if label == "" {
+			label = "endpoint"
+		}
+		color = query.Get("color")
 		return
 	}
 	
-	// Fallback: use the path as label
-	label = path
+	// Fallback: the first part of the path as label, the rest as message
+	parts := strings.SplitN(strings.TrimSuffix(path, ".svg"), "/", 2)
+	label = parts[0]
 	message = ""
-	color = ""
+	if len(parts) == 2 {
+		message = parts[1]
+	}
+	color = query.Get("color")
 	
 	return
 }
 
+
 // urlDecode decodes URL-encoded strings, handling special characters
 func urlDecode(s string) string {
 	// Replace URL-encoded characters
@@ -182,18 +436,122 @@ func urlDecode(s string) string {
 	return decoded
 }
 
-// formatBadgeText formats the badge as colored text
-func formatBadgeText(label, message, color string, config *Config) string {
-	// Format: [label: message] or just [label] if no message
+// badgeStyle holds the query parameters changing the look of a badge
+type badgeStyle struct {
+	// style is flat, flat-square, plastic, for-the-badge or social
+	style      string
+	labelColor string
+}
+
+// parseBadgeStyle reads the style parameters of a shields.io badge URL
+func parseBadgeStyle(badgeURL string) badgeStyle {
+	parsedURL, err := url.Parse(badgeURL)
+	if err != nil {
+		return badgeStyle{}
+	}
+	query := parsedURL.Query()
+	labelColor := query.Get("labelColor")
+	if labelColor == "" {
+		labelColor = query.Get("colorA")
+	}
+	return badgeStyle{style: query.Get("style"), labelColor: labelColor}
+}
+
+// shieldsColors are the named colors of shields.io
+var shieldsColors = map[string]string{
+	"brightgreen":   "#44cc11",
+	"green":         "#97ca00",
+	"yellowgreen":   "#a4a61d",
+	"yellow":        "#dfb317",
+	"orange":        "#fe7d37",
+	"red":           "#e05d44",
+	"blue":          "#007ec6",
+	"grey":          "#555555",
+	"gray":          "#555555",
+	"lightgrey":     "#9f9f9f",
+	"lightgray":     "#9f9f9f",
+	"success":       "#44cc11",
+	"important":     "#fe7d37",
+	"critical":      "#e05d44",
+	"informational": "#007ec6",
+	"inactive":      "#9f9f9f",
+	"blueviolet":    "#8a2be2",
+	"purple":        "#800080",
+	"pink":          "#ffc0cb",
+	"white":         "#ffffff",
+	"black":         "#000000",
+}
+
+var badgeHexPattern = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// badgeColor resolves a shields.io color, named or hex, to a #rrggbb color
+func badgeColor(color, fallback string) string {
+	if hex, ok := shieldsColors[strings.ToLower(color)]; ok {
+		return hex
+	}
+	if match := badgeHexPattern.FindStringSubmatch(color); match != nil {
+		hex := strings.ToLower(match[1])
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		return "#" + hex
+	}
+	return fallback
+}
+
+// badgeTextColor returns the text color shields.io puts on a background:
+// dark on bright colors, white otherwise
+func badgeTextColor(background string) string {
+	c, err := parseHexColor(background)
+	if err == nil && (299*c.r+587*c.g+114*c.b)/1000 > 176 {
+		return "#333333"
+	}
+	return "#ffffff"
+}
+
+// badgeSegment draws a part of a badge pill on its background color
+func badgeSegment(text, background string) string {
+	seq, _ := colorSequence(background, 48)
+	fg, _ := colorSequence(badgeTextColor(background), 38)
+	return seq + fg + " " + text + " "
+}
+
+// formatBadgeText formats the badge as a two-tone pill, a grey label segment
+// followed by a message segment in the badge color, or as plain text when
+// colors are off
+func formatBadgeText(label, message, color string, style badgeStyle, config *Config) string {
+	if colorProfile == ProfileNoColor {
+		// Format: [label: message] or just [label] if no message
+		if message != "" && label != "" {
+			return fmt.Sprintf("[%s: %s]", label, message)
+		} else if label != "" {
+			return fmt.Sprintf("[%s]", label)
+		}
+		return "[badge]"
+	}
 	
-	var text string
-	if message != "" && label != "" {
-		text = fmt.Sprintf("[%s: %s]", label, message)
-	} else if label != "" {
-		text = fmt.Sprintf("[%s]", label)
-	} else {
-		text = "[badge]"
+	if label == "" && message == "" {
+		label = "badge"
 	}
+	labelColor := badgeColor(style.labelColor, shieldsColors["grey"])
+	messageColor := badgeColor(color, shieldsColors["lightgrey"])
 	
-	return text
+	switch style.style {
+	case "for-the-badge":
+		label, message = strings.ToUpper(label), strings.ToUpper(message)
+	case "social":
+		// Social badges are light, the label capitalized
+		if r, size := utf8.DecodeRuneInString(label); size > 0 {
+			label = string(unicode.ToUpper(r)) + label[size:]
+		}
+		labelColor, messageColor = "#fcfcfc", "#ffffff"
+	}
+	
+	if message == "" {
+		return badgeSegment(label, messageColor) + sgrReset
+	}
+	if label == "" {
+		return badgeSegment(message, messageColor) + sgrReset
+	}
+	return badgeSegment(label, labelColor) + badgeSegment(message, messageColor) + sgrReset
 }
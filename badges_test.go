@@ -45,7 +45,172 @@ func TestParseShieldsBadge(t *testing.T) {
 	}
 }
 
+func TestParseBadge(t *testing.T) {
+	tests := []struct {
+		name            string
+		badgeURL        string
+		expectedLabel   string
+		expectedMsg     string
+		expectedColor   string
+		expectedIsBadge bool
+	}{
+		{
+			name:            "GitHub Actions workflow",
+			badgeURL:        "https://github.com/guttermonk/bleamd/actions/workflows/ci.yml/badge.svg?branch=main",
+			expectedLabel:   "ci",
+			expectedMsg:     "main",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "GitHub Actions workflow by name",
+			badgeURL:        "https://github.com/guttermonk/bleamd/workflows/Go%20build/badge.svg",
+			expectedLabel:   "Go build",
+			expectedMsg:     "workflow",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "shields.io workflow status",
+			badgeURL:        "https://img.shields.io/github/actions/workflow/status/guttermonk/bleamd/release.yaml?branch=master",
+			expectedLabel:   "release",
+			expectedMsg:     "master",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "badgen.net static",
+			badgeURL:        "https://badgen.net/badge/license/MIT/green",
+			expectedLabel:   "license",
+			expectedMsg:     "MIT",
+			expectedColor:   "green",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "badgen.net GitHub stars",
+			badgeURL:        "https://badgen.net/github/stars/guttermonk/bleamd",
+			expectedLabel:   "stars",
+			expectedMsg:     "guttermonk/bleamd",
+			expectedColor:   "yellow",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "badgen.net other",
+			badgeURL:        "https://badgen.net/docker/pulls/library/alpine?color=cyan",
+			expectedLabel:   "docker",
+			expectedMsg:     "pulls/library/alpine",
+			expectedColor:   "cyan",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "codecov",
+			badgeURL:        "https://codecov.io/gh/guttermonk/bleamd/branch/main/graph/badge.svg?token=ABC",
+			expectedLabel:   "codecov",
+			expectedMsg:     "guttermonk/bleamd",
+			expectedColor:   "#f01f7a",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "shields.io codecov",
+			badgeURL:        "https://img.shields.io/codecov/c/github/guttermonk/bleamd",
+			expectedLabel:   "coverage",
+			expectedMsg:     "guttermonk/bleamd",
+			expectedColor:   "#f01f7a",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "Go Report Card",
+			badgeURL:        "https://goreportcard.com/badge/github.com/guttermonk/bleamd",
+			expectedLabel:   "go report",
+			expectedMsg:     "guttermonk/bleamd",
+			expectedColor:   "#00add8",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "pkg.go.dev",
+			badgeURL:        "https://pkg.go.dev/badge/github.com/guttermonk/bleamd.svg",
+			expectedLabel:   "go reference",
+			expectedMsg:     "guttermonk/bleamd",
+			expectedColor:   "#007d9c",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "npm version",
+			badgeURL:        "https://img.shields.io/npm/v/@scope/package",
+			expectedLabel:   "npm",
+			expectedMsg:     "@scope/package",
+			expectedColor:   "blue",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "PyPI version",
+			badgeURL:        "https://badgen.net/pypi/v/requests",
+			expectedLabel:   "pypi",
+			expectedMsg:     "requests",
+			expectedColor:   "blue",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "crates.io version",
+			badgeURL:        "https://img.shields.io/crates/v/serde.svg",
+			expectedLabel:   "crates.io",
+			expectedMsg:     "serde",
+			expectedColor:   "blue",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "badge.fury.io",
+			badgeURL:        "https://badge.fury.io/py/requests.svg",
+			expectedLabel:   "pypi",
+			expectedMsg:     "requests",
+			expectedColor:   "blue",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "shields.io endpoint",
+			badgeURL:        "https://img.shields.io/endpoint?url=https%3A%2F%2Fexample.com%2Fbadges%2Fcoverage.json",
+			expectedLabel:   "coverage",
+			expectedMsg:     "example.com",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "shields.io endpoint with label",
+			badgeURL:        "https://img.shields.io/endpoint?url=https://example.com/b.json&label=tests&color=orange",
+			expectedLabel:   "tests",
+			expectedMsg:     "example.com",
+			expectedColor:   "orange",
+			expectedIsBadge: true,
+		},
+		{
+			name:            "shields.io other",
+			badgeURL:        "https://img.shields.io/github/last-commit/guttermonk/bleamd",
+			expectedLabel:   "github",
+			expectedMsg:     "last-commit/guttermonk/bleamd",
+			expectedIsBadge: true,
+		},
+		{
+			name:     "GitHub image",
+			badgeURL: "https://github.com/guttermonk/bleamd/raw/main/screenshot.png",
+		},
+		{
+			name:     "local image",
+			badgeURL: "docs/badge.svg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label, message, color, ok := parseBadge(tt.badgeURL)
+			if ok != tt.expectedIsBadge {
+				t.Fatalf("parseBadge() ok = %v, expected %v", ok, tt.expectedIsBadge)
+			}
+			if label != tt.expectedLabel || message != tt.expectedMsg || color != tt.expectedColor {
+				t.Errorf("parseBadge() = %q, %q, %q, expected %q, %q, %q", label, message, color, tt.expectedLabel, tt.expectedMsg, tt.expectedColor)
+			}
+		})
+	}
+}
+
 func TestProcessBadges(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
 	tests := []struct {
 		name     string
 		input    string
@@ -66,16 +231,118 @@ func TestProcessBadges(t *testing.T) {
 			input:    "![Build](https://img.shields.io/badge/build-passing-green)",
 			contains: "[build: passing]",
 		},
+		{
+			name:     "Linked workflow badge",
+			input:    "[![CI](https://github.com/guttermonk/bleamd/actions/workflows/ci.yml/badge.svg)](https://github.com/guttermonk/bleamd/actions)",
+			contains: "[[ci: workflow]](https://github.com/guttermonk/bleamd/actions)",
+		},
+		{
+			name:     "Image that isn't a badge",
+			input:    "[![Demo](https://example.com/demo.gif)](https://example.com) ![Logo](logo.png)",
+			contains: "[![Demo](https://example.com/demo.gif)](https://example.com) ![Logo](logo.png)",
+		},
 	}
 
 	config := DefaultConfig()
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := processBadges(tt.input, config)
-			if !strings.Contains(result, tt.contains) {
+			result, pills := processBadges(tt.input, config)
+			if result := string(insertBadges([]byte(result), pills)); !strings.Contains(result, tt.contains) {
 				t.Errorf("Expected output to contain %q, got %q", tt.contains, result)
 			}
 		})
 	}
 }
+
+func TestFormatBadgeText(t *testing.T) {
+	useColorProfile(t, ProfileTrueColor)
+
+	tests := []struct {
+		name     string
+		badgeURL string
+		expected string
+	}{
+		{
+			name:     "named color",
+			badgeURL: "https://img.shields.io/badge/build-passing-brightgreen",
+			expected: "\x1b[48;2;85;85;85m\x1b[38;2;255;255;255m build \x1b[48;2;68;204;17m\x1b[38;2;255;255;255m passing \x1b[0m",
+		},
+		{
+			name:     "hex colors",
+			badgeURL: "https://img.shields.io/badge/coverage-95%25-ff0?labelColor=%23abc",
+			expected: "\x1b[48;2;170;187;204m\x1b[38;2;51;51;51m coverage \x1b[48;2;255;255;0m\x1b[38;2;51;51;51m 95% \x1b[0m",
+		},
+		{
+			name:     "color query and unknown color",
+			badgeURL: "https://img.shields.io/badge/docs-latest-nope?color=red",
+			expected: "\x1b[48;2;85;85;85m\x1b[38;2;255;255;255m docs \x1b[48;2;224;93;68m\x1b[38;2;255;255;255m latest \x1b[0m",
+		},
+		{
+			name:     "default color",
+			badgeURL: "https://img.shields.io/badge/chat-discord",
+			expected: "\x1b[48;2;85;85;85m\x1b[38;2;255;255;255m chat \x1b[48;2;159;159;159m\x1b[38;2;255;255;255m discord \x1b[0m",
+		},
+		{
+			name:     "for-the-badge style",
+			badgeURL: "https://img.shields.io/github/license/guttermonk/bleamd.svg?style=for-the-badge",
+			expected: "\x1b[48;2;85;85;85m\x1b[38;2;255;255;255m LICENSE \x1b[48;2;0;126;198m\x1b[38;2;255;255;255m GUTTERMONK/BLEAMD \x1b[0m",
+		},
+		{
+			name:     "social style",
+			badgeURL: "https://img.shields.io/github/stars/guttermonk/bleamd?style=social",
+			expected: "\x1b[48;2;252;252;252m\x1b[38;2;51;51;51m Stars \x1b[48;2;255;255;255m\x1b[38;2;51;51;51m guttermonk/bleamd \x1b[0m",
+		},
+		{
+			name:     "markdown characters",
+			badgeURL: "https://img.shields.io/badge/v-1.0_rc-blue",
+			expected: "\x1b[48;2;85;85;85m\x1b[38;2;255;255;255m v \x1b[48;2;0;126;198m\x1b[38;2;255;255;255m 1.0_rc \x1b[0m",
+		},
+	}
+
+	config := DefaultConfig()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label, message, color := parseShieldsBadge(tt.badgeURL)
+			if got := formatBadgeText(label, message, color, parseBadgeStyle(tt.badgeURL), config); got != tt.expected {
+				t.Errorf("formatBadgeText() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderLinkedBadgeRow(t *testing.T) {
+	useColorProfile(t, ProfileTrueColor)
+
+	raw := "[![GitHub license](https://img.shields.io/github/license/guttermonk/bleamd.svg)](https://github.com/guttermonk/bleamd/blob/master/LICENSE) " +
+		"[![GitHub stars](https://img.shields.io/github/stars/guttermonk/bleamd)](https://github.com/guttermonk/bleamd/stargazers) " +
+		"[![CI](https://github.com/guttermonk/bleamd/actions/workflows/ci.yml/badge.svg)](https://github.com/guttermonk/bleamd/actions) " +
+		"[![Go](https://img.shields.io/badge/go-1.23-blue)](https://go.dev)\n"
+	pills := []string{" license  guttermonk/bleamd ", " stars  guttermonk/bleamd ", " ci  workflow ", " go  1.23 "}
+
+	for _, width := range []int{200, 60, 40} {
+		m := newModel([]byte(raw), DefaultConfig())
+		m.width = width
+		rendered, _ := m.renderDocument()
+
+		var lines []string
+		for _, line := range strings.Split(string(rendered), "\n") {
+			if plain := strings.TrimSpace(stripANSI(line)); plain != "" {
+				lines = append(lines, stripANSI(line))
+			}
+		}
+		text := strings.Join(lines, "\n")
+		for _, pill := range pills {
+			if !strings.Contains(text, pill) {
+				t.Errorf("Expected the pill %q whole at width %d, got:\n%s", pill, width, text)
+			}
+		}
+		if width == 200 && len(lines) != 1 {
+			t.Errorf("Expected the badges on one line at width 200, got:\n%s", text)
+		}
+		if strings.Contains(string(rendered), badgeMarker) {
+			t.Errorf("Expected no placeholder left at width %d", width)
+		}
+	}
+}
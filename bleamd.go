@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,49 +13,76 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mattn/go-isatty"
+	"github.com/mattn/go-runewidth"
 	"github.com/pkg/errors"
 )
 
-const padding = 4
-
 func main() {
-	if len(os.Args) >= 2 && (os.Args[1] == "version" || os.Args[1] == "--version") {
+	opts, args, err := parseOptions(os.Args[1:])
+	if err != nil {
+		exitError(err)
+	}
+
+	profile, err := parseColorMode(opts.colorMode)
+	if err != nil {
+		exitError(err)
+	}
+	setColorProfile(profile)
+
+	if len(args) >= 1 && (args[0] == "version" || args[0] == "--version") {
 		printVersion()
 		return
 	}
 
-	if len(os.Args) >= 2 && (os.Args[1] == "--init-config") {
+	if len(args) >= 1 && (args[0] == "--init-config") {
 		theme := "default"
-		if len(os.Args) >= 3 {
-			theme = os.Args[2]
+		if len(args) >= 2 {
+			theme = args[1]
 		}
 		initConfig(theme)
 		return
 	}
 
-	if len(os.Args) >= 2 && (os.Args[1] == "--config-path") {
-		fmt.Printf("Config file location: %s\n", getConfigPath())
+	if len(args) >= 1 && (args[0] == "--config-path") {
+		printConfigInfo(opts.theme)
 		return
 	}
 
+	if len(args) >= 1 && (args[0] == "--list-themes") {
+		for _, name := range ThemeNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		config = DefaultConfig()
+	}
+	if opts.theme != "" {
+		if err := config.UseTheme(opts.theme); err != nil {
+			exitError(err)
+		}
+	}
+
 	var content []byte
 
-	switch len(os.Args) {
-	case 1:
+	switch len(args) {
+	case 0:
 		if isatty.IsTerminal(os.Stdin.Fd()) {
-			exitError(fmt.Errorf("usage: %s <file.md>", os.Args[0]))
+			exitError(fmt.Errorf("usage: %s [--theme <name>] [--color=auto|truecolor|256|16|never] <file.md>", os.Args[0]))
 		}
 		data, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			exitError(errors.Wrap(err, "error while reading STDIN"))
 		}
 		content = data
-	case 2:
-		data, err := ioutil.ReadFile(os.Args[1])
+	case 1:
+		data, err := ioutil.ReadFile(args[0])
 		if err != nil {
 			exitError(errors.Wrap(err, "error while reading file"))
 		}
-		err = os.Chdir(path.Dir(os.Args[1]))
+		err = os.Chdir(path.Dir(args[0]))
 		if err != nil {
 			exitError(err)
 		}
@@ -64,7 +92,13 @@ func main() {
 		exitError(fmt.Errorf("only one file is supported"))
 	}
 
-	model := newModel(content)
+	// The terminal can't be asked about images once the UI runs
+	hasImages := bytes.Contains(content, []byte("![")) || bytes.Contains(bytes.ToLower(content), []byte("<img"))
+	if hasImages && (config.Images == imagesAuto || config.Images == imagesProtocol) {
+		currentGraphics()
+	}
+	
+	model := newModel(content, config)
 	
 	// Use default mouse mode (button clicks only) to allow text selection
 	// WithMouseAllMotion() would capture all mouse events and prevent selection
@@ -74,22 +108,55 @@ func main() {
 	}
 }
 
+// cliOptions holds the options given on the command line
+type cliOptions struct {
+	colorMode string
+	theme     string
+}
+
+// parseOptions extracts the --option flags from args and returns the
+// remaining positional arguments
+func parseOptions(args []string) (cliOptions, []string, error) {
+	var opts cliOptions
+	var rest []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--color="):
+			opts.colorMode = strings.TrimPrefix(arg, "--color=")
+		case arg == "--color":
+			if i+1 >= len(args) {
+				return opts, nil, fmt.Errorf("--color requires a value")
+			}
+			i++
+			opts.colorMode = args[i]
+		case strings.HasPrefix(arg, "--theme="):
+			opts.theme = strings.TrimPrefix(arg, "--theme=")
+		case arg == "--theme":
+			if i+1 >= len(args) {
+				return opts, nil, fmt.Errorf("--theme requires a value")
+			}
+			i++
+			opts.theme = args[i]
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return opts, rest, nil
+}
+
 func exitError(err error) {
 	_, _ = fmt.Fprintln(os.Stderr, err)
 	os.Exit(1)
 }
 
 func initConfig(theme string) {
-	var config *Config
-	
-	switch theme {
-	case "onedark", "one-dark":
-		config = OneDarkConfig()
-	case "default":
-		config = DefaultConfig()
-	default:
+	config, err := LoadTheme(theme)
+	if err != nil {
 		fmt.Printf("Unknown theme: %s\n", theme)
-		fmt.Println("Available themes: default, onedark")
+		fmt.Printf("Available themes: %s\n", strings.Join(ThemeNames(), ", "))
 		os.Exit(1)
 	}
 	
@@ -118,6 +185,41 @@ func initConfig(theme string) {
 	fmt.Println("  \"#00ffff\" - Cyan")
 }
 
+// printConfigInfo shows where the configuration lives and which theme it
+// resolves to on this terminal
+func printConfigInfo(themeFlag string) {
+	configPath := getConfigPath()
+	fmt.Printf("Config file location: %s\n", configPath)
+	fmt.Printf("User themes directory: %s\n", getUserThemesDir())
+	fmt.Printf("Color profile: %s\n", colorProfile)
+	fmt.Printf("Terminal background: %s\n", currentBackground())
+	fmt.Printf("Image protocol: %s\n", currentGraphics())
+	
+	config := DefaultConfig()
+	if data, err := ioutil.ReadFile(configPath); err == nil {
+		config, err = parseConfig(data)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	
+	switch {
+	case themeFlag != "":
+		fmt.Printf("Active theme: %s (from --theme)\n", themeFlag)
+	case config.Theme != "":
+		fmt.Printf("Active theme: %s (from %s)\n", config.Theme, config.themeSource)
+	case config.Extends != "":
+		fmt.Printf("Active theme: custom (extends %s)\n", config.Extends)
+	default:
+		fmt.Println("Active theme: custom (colors from the config file)")
+	}
+	
+	fmt.Println("\nValue sources:")
+	for _, key := range configKeys() {
+		fmt.Printf("  %-40s %s\n", key, config.ValueSource(key))
+	}
+}
+
 type model struct {
 	content         []byte
 	raw             string
@@ -143,12 +245,40 @@ type model struct {
 	linkPositions []linkPosition
 	hoveredURL    string
 	
+	// scroll positions to return to after following footnote links
+	footnoteReturns []int
+	
+	// show mermaid blocks as source instead of diagrams
+	diagramSource bool
+	
+	// front matter of the document, shown whole or as a header
+	meta            frontMatter
+	fullFrontMatter bool
+	
+	// <details> blocks expanded or collapsed against the document's choice
+	detailsToggled map[int]bool
+	
+	// first column shown of the tables scrolled on their own
+	tableOffsets map[int]int
+	
+	// message shown in the status bar until the next key
+	notice string
+	
+	// keys and count typed so far of a key sequence such as "g g", and
+	// the id of its timeout
+	pendingKeys  []string
+	pendingCount int
+	pendingID    int
+	
+	// headings of the rendered document, and the table of contents pane
+	// listing them
+	headings   []heading
+	tocActive  bool
+	tocFocused bool
+	tocCursor  int
+	
 	// styles
-	styles struct {
-		helpBox   lipgloss.Style
-		searchBox lipgloss.Style
-		statusBar lipgloss.Style
-	}
+	styles modelStyles
 	
 	// mode tracking for status bar
 	mode string
@@ -157,15 +287,20 @@ type model struct {
 	mouseCaptureEnabled bool
 }
 
-func newModel(content []byte) model {
-	config, err := LoadConfig()
-	if err != nil {
-		config = DefaultConfig()
-	}
+type modelStyles struct {
+	helpBox   lipgloss.Style
+	searchBox lipgloss.Style
+	statusBar lipgloss.Style
+}
+
+func newModel(content []byte, config *Config) model {
+	// The front matter is shown in a header rather than rendered
+	meta, body := splitFrontMatter(string(content))
 	
 	m := model{
 		content:             content,
-		raw:                 string(content),
+		raw:                 body,
+		meta:                meta,
 		width:               80, // Default width, will be updated on first WindowSizeMsg
 		search:              NewSearchState(config),
 		config:              config,
@@ -173,8 +308,11 @@ func newModel(content []byte) model {
 		mouseCaptureEnabled: true, // Start with mouse capture enabled for hover
 	}
 	
+	// Point the renderer's own colors at the theme
+	installRendererPalette(&config.Colors)
+	
 	// Initial render with default width
-	m.renderedContent = m.render()
+	m.renderedContent, m.headings = m.renderDocument()
 	// Count lines
 	lineCount := 0
 	for _, b := range m.renderedContent {
@@ -184,34 +322,43 @@ func newModel(content []byte) model {
 	}
 	m.lines = lineCount
 	
-	// Initialize styles
+	m.styles = newModelStyles(config)
+	
+	return m
+}
+
+// newModelStyles builds the styles of the help box, search box and status bar
+// from the configured colors
+func newModelStyles(config *Config) modelStyles {
+	var styles modelStyles
+	
 	// Initialize help box style with configurable border color
 	helpBoxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		Padding(1, 2)
 	if config.Colors.HelpBoxBorder != "" {
-		if colorCode, err := hexToANSI(config.Colors.HelpBoxBorder); err == nil {
-			helpBoxStyle = helpBoxStyle.BorderForeground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+		if color, ok := config.Colors.GetLipglossColor(config.Colors.HelpBoxBorder); ok {
+			helpBoxStyle = helpBoxStyle.BorderForeground(color)
 		}
 	}
-	m.styles.helpBox = helpBoxStyle
+	styles.helpBox = helpBoxStyle
 	
 	// Initialize search box style with configurable border color  
 	searchBoxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		Padding(0, 1)
 	if config.Colors.SearchBoxBorder != "" {
-		if colorCode, err := hexToANSI(config.Colors.SearchBoxBorder); err == nil {
-			searchBoxStyle = searchBoxStyle.BorderForeground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+		if color, ok := config.Colors.GetLipglossColor(config.Colors.SearchBoxBorder); ok {
+			searchBoxStyle = searchBoxStyle.BorderForeground(color)
 		}
 	}
-	m.styles.searchBox = searchBoxStyle
+	styles.searchBox = searchBoxStyle
 	
-	m.styles.statusBar = lipgloss.NewStyle().
+	styles.statusBar = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241")).
 		MarginTop(1)
 	
-	return m
+	return styles
 }
 
 func (m model) Init() tea.Cmd {
@@ -220,14 +367,25 @@ func (m model) Init() tea.Cmd {
 	return tea.EnableMouseAllMotion
 }
 
+// Update handles a message, clearing the screen when images move since
+// terminals keep them drawn over the text taking their place
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	before := m.imagesOnScreen()
+	updated, cmd := m.update(msg)
+	if next, ok := updated.(model); ok && next.imagesOnScreen() != before {
+		cmd = tea.Batch(cmd, tea.ClearScreen)
+	}
+	return updated, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		// Re-render content with new width
 		if len(m.raw) > 0 {
-			m.renderedContent = m.render()
+			m.renderedContent, m.headings = m.renderDocument()
 			// Count lines
 			lineCount := 0
 			for _, b := range m.renderedContent {
@@ -247,6 +405,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		
 	case tea.KeyMsg:
 		return m.handleKeyMsg(msg)
+		
+	case keyTimeoutMsg:
+		return m.keyTimeout(msg)
+		
+	case codeBlockCopiedMsg:
+		return m.codeBlockCopied(msg), nil
 	}
 	
 	return m, nil
@@ -341,11 +505,17 @@ func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 		if msg.Button == tea.MouseButtonWheelDown {
 			return m.scrollDown(), nil
 		}
+		
+		// Handle click on the table of contents
+		if msg.Button == tea.MouseButtonLeft && m.tocActive && msg.X >= m.contentWidth() {
+			return m.clickTOC(msg.Y), nil
+		}
 	}
 	
 	// Check if mouse is hovering over any link
 	previousHoveredURL := m.hoveredURL
 	m.hoveredURL = ""
+	var cmd tea.Cmd
 	
 	for _, link := range m.linkPositions {
 		// Check if mouse position is within link bounds
@@ -354,7 +524,20 @@ func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 			
 			// Handle click on link
 			if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
-				openURL(link.url)
+				if isFootnoteURL(link.url) {
+					m = m.followFootnote(link.url)
+					m.hoveredURL = ""
+				} else if isDetailsURL(link.url) {
+					m = m.toggleDetails(link.url)
+					m.hoveredURL = ""
+				} else if isTableURL(link.url) {
+					m = m.scrollTable(link.url)
+					m.hoveredURL = ""
+				} else if isCodeBlockURL(link.url) {
+					cmd = m.copyCodeBlock(link.url)
+				} else {
+					openURL(link.url)
+				}
 			}
 			break
 		}
@@ -366,7 +549,7 @@ func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 		m = m.updateLinkPositions()
 	}
 	
-	return m, nil
+	return m, cmd
 }
 
 func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -405,7 +588,25 @@ func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	
 	// Handle navigation keys based on config
 	key := msg.String()
+	m.notice = ""
+	
+	// Escape drops the count and keys typed so far
+	if key == "esc" && m.pendingHint() != "" {
+		m.pendingKeys, m.pendingCount = nil, 0
+		return m, nil
+	}
 	
+	// Keys of a sequence such as "g g" wait for the rest of the sequence
+	m, key, count, cmd := m.readKey(key)
+	if key == "" {
+		return m, cmd
+	}
+	return m.runKeys(key, count)
+}
+
+// runKeys runs the action bound to a sequence of keys, joined by spaces.
+// Motions are repeated count times.
+func (m model) runKeys(key string, count int) (tea.Model, tea.Cmd) {
 	// In search-nav mode, allow escape or q to exit and clear search
 	if m.mode == "search-nav" {
 		if key == "esc" || key == "escape" {
@@ -417,39 +618,77 @@ func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	}
 	
+	// The table of contents takes the navigation keys while it has the focus
+	if m.isKeyInSlice(key, m.config.Keybindings.ToggleTOC) {
+		return m.toggleTOC(), nil
+	}
+	if m.tocFocused {
+		if updated, handled := m.handleTOCKey(key, count); handled {
+			return updated, nil
+		}
+	} else if m.tocActive && key == "tab" {
+		m.tocFocused = true
+		m.tocCursor = max(m.currentSection(), 0)
+		return m, nil
+	}
+	
 	// Check if key matches any configured keybinding
 	if m.isKeyInSlice(key, m.config.Keybindings.ScrollUp) {
-		return m.scrollUp(), nil
+		return m.repeat(count, model.scrollUp), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.ScrollDown) {
-		return m.scrollDown(), nil
+		return m.repeat(count, model.scrollDown), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.ScrollLeft) {
-		return m.scrollLeft(), nil
+		return m.repeat(count, model.scrollLeft), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.ScrollRight) {
-		return m.scrollRight(), nil
+		return m.repeat(count, model.scrollRight), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.PageUp) {
-		return m.pageUp(), nil
+		return m.repeat(count, model.pageUp), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.PageDown) {
-		return m.pageDown(), nil
+		return m.repeat(count, model.pageDown), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.GoToTop) {
+		if count > 0 {
+			return m.goToLine(count), nil
+		}
 		return m.goToTop(), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.GoToBottom) {
+		if count > 0 {
+			return m.goToLine(count), nil
+		}
 		return m.goToBottom(), nil
 	}
+	if m.isKeyInSlice(key, m.config.Keybindings.NextHeading) {
+		return m.repeat(count, model.nextHeading), nil
+	}
+	if m.isKeyInSlice(key, m.config.Keybindings.PrevHeading) {
+		return m.repeat(count, model.prevHeading), nil
+	}
+	if m.isKeyInSlice(key, m.config.Keybindings.NextSiblingHeading) {
+		return m.repeat(count, model.nextSiblingHeading), nil
+	}
+	if m.isKeyInSlice(key, m.config.Keybindings.ParentHeading) {
+		return m.repeat(count, model.parentHeading), nil
+	}
+	if m.isKeyInSlice(key, m.config.Keybindings.FollowFootnote) {
+		return m.followVisibleFootnote(), nil
+	}
+	if m.isKeyInSlice(key, m.config.Keybindings.FootnoteBack) {
+		return m.footnoteBack(), nil
+	}
 	if m.isKeyInSlice(key, m.config.Keybindings.StartSearch) {
 		return m.startSearch(), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.NextMatch) {
-		return m.nextMatch(), nil
+		return m.repeat(count, model.nextMatch), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.PrevMatch) {
-		return m.prevMatch(), nil
+		return m.repeat(count, model.prevMatch), nil
 	}
 	if m.isKeyInSlice(key, m.config.Keybindings.ClearSearch) {
 		return m.clearSearch(), nil
@@ -463,6 +702,28 @@ func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	}
 	
+	if m.isKeyInSlice(key, m.config.Keybindings.CycleTheme) {
+		return m.cycleTheme(), nil
+	}
+	
+	if m.isKeyInSlice(key, m.config.Keybindings.ToggleDiagrams) {
+		m.diagramSource = !m.diagramSource
+		return m.refresh(), nil
+	}
+	
+	if m.isKeyInSlice(key, m.config.Keybindings.ToggleDetails) {
+		return m.toggleVisibleDetails(), nil
+	}
+	
+	if m.isKeyInSlice(key, m.config.Keybindings.CopyCodeBlock) {
+		return m.copyVisibleCodeBlock()
+	}
+	
+	if m.isKeyInSlice(key, m.config.Keybindings.ToggleFrontMatter) {
+		m.fullFrontMatter = !m.fullFrontMatter
+		return m.refresh(), nil
+	}
+	
 	// Toggle mouse capture mode
 	if m.isKeyInSlice(key, m.config.Keybindings.ToggleMouse) {
 		m.mouseCaptureEnabled = !m.mouseCaptureEnabled
@@ -477,49 +738,13 @@ func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// isKeyInSlice tells whether a sequence of keys, joined by spaces, is one of
+// the keybindings keys
 func (m model) isKeyInSlice(key string, keys []string) bool {
 	for _, k := range keys {
-		if key == k || key == strings.ToLower(k) {
+		if strings.Join(parseKeySequence(k), " ") == key {
 			return true
 		}
-		// Handle Ctrl+key format conversion from C-x to ctrl+x
-		if strings.HasPrefix(k, "C-") {
-			ctrlKey := "ctrl+" + strings.ToLower(strings.TrimPrefix(k, "C-"))
-			if key == ctrlKey {
-				return true
-			}
-		}
-		// Handle special key mappings
-		switch k {
-		case "Up", "ArrowUp":
-			if key == "up" {
-				return true
-			}
-		case "Down", "ArrowDown":
-			if key == "down" {
-				return true
-			}
-		case "Left", "ArrowLeft":
-			if key == "left" {
-				return true
-			}
-		case "Right", "ArrowRight":
-			if key == "right" {
-				return true
-			}
-		case "PageUp", "PgUp":
-			if key == "pgup" {
-				return true
-			}
-		case "PageDown", "PgDn", "PageDn":
-			if key == "pgdown" {
-				return true
-			}
-		case "Space", " ":
-			if key == " " {
-				return true
-			}
-		}
 	}
 	return false
 }
@@ -533,22 +758,31 @@ func (m model) renderStatusBar() string {
 		
 		// Apply hovered link URL color if configured, otherwise use status bar text color
 		if m.config.Colors.HoveredLinkURL != "" {
-			if colorCode, err := hexToANSI(m.config.Colors.HoveredLinkURL); err == nil {
-				style = style.Foreground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+			if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.HoveredLinkURL); ok {
+				style = style.Foreground(color)
 			}
 		} else if m.config.Colors.StatusBarText != "" {
-			if colorCode, err := hexToANSI(m.config.Colors.StatusBarText); err == nil {
-				style = style.Foreground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+			if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.StatusBarText); ok {
+				style = style.Foreground(color)
 			}
 		}
 		
 		// Apply background color if configured
 		if m.config.Colors.StatusBarBg != "" {
-			if colorCode, err := hexToANSI(m.config.Colors.StatusBarBg); err == nil {
-				style = style.Background(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+			if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.StatusBarBg); ok {
+				style = style.Background(color)
 			}
 		}
 		
+		if isDetailsURL(m.hoveredURL) {
+			return style.Render("Click to expand or collapse")
+		}
+		if isTableURL(m.hoveredURL) {
+			return style.Render("Click to scroll the table")
+		}
+		if isCodeBlockURL(m.hoveredURL) {
+			return style.Render("Click to copy the code block")
+		}
 		return style.Render("🔗 " + m.hoveredURL)
 	}
 	
@@ -598,9 +832,24 @@ func (m model) renderStatusBar() string {
 			fmt.Sprintf("%s/%s page", firstKey(m.config.Keybindings.PageUp), firstKey(m.config.Keybindings.PageDown)),
 			fmt.Sprintf("%s search", firstKey(m.config.Keybindings.StartSearch)),
 			fmt.Sprintf("%s mouse:%s", firstKey(m.config.Keybindings.ToggleMouse), mouseMode),
+			fmt.Sprintf("%s theme:%s", firstKey(m.config.Keybindings.CycleTheme), m.themeName()),
 			fmt.Sprintf("%s help", firstKey(m.config.Keybindings.ShowHelp)),
 			fmt.Sprintf("%s quit", firstKey(m.config.Keybindings.Quit)),
 		}
+		if m.tocFocused {
+			items = []string{
+				fmt.Sprintf("%s/%s select", firstKey(m.config.Keybindings.ScrollUp), firstKey(m.config.Keybindings.ScrollDown)),
+				"Enter go to heading",
+				"Tab back to text",
+				fmt.Sprintf("%s close contents", firstKey(m.config.Keybindings.ToggleTOC)),
+			}
+		}
+		if title := m.meta.title(); title != "" {
+			items = append([]string{"📄 " + runewidth.Truncate(title, 40, "…")}, items...)
+		}
+		if m.notice != "" {
+			items = append([]string{"📋 " + m.notice}, items...)
+		}
 	case "search":
 		items = []string{
 			"Enter execute",
@@ -621,6 +870,11 @@ func (m model) renderStatusBar() string {
 		}
 	}
 	
+	// Show the count and keys typed so far of a key sequence
+	if hint := m.pendingHint(); hint != "" && m.mode != "help" && m.mode != "search" {
+		items = append([]string{"⌨ " + hint}, items...)
+	}
+	
 	// Join items with separator
 	statusText := strings.Join(items, " │ ")
 	
@@ -631,15 +885,15 @@ func (m model) renderStatusBar() string {
 	
 	// Apply text color if configured
 	if m.config.Colors.StatusBarText != "" {
-		if colorCode, err := hexToANSI(m.config.Colors.StatusBarText); err == nil {
-			style = style.Foreground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+		if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.StatusBarText); ok {
+			style = style.Foreground(color)
 		}
 	}
 	
 	// Apply background color if configured (empty = transparent)
 	if m.config.Colors.StatusBarBg != "" {
-		if colorCode, err := hexToANSI(m.config.Colors.StatusBarBg); err == nil {
-			style = style.Background(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+		if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.StatusBarBg); ok {
+			style = style.Background(color)
 		}
 	}
 	
@@ -696,6 +950,9 @@ func (m model) View() string {
 	
 	visibleLines := lines[startLine:endLine]
 	
+	// Draw the images that are wholly on screen
+	visibleLines = showImages(visibleLines, m.xOffset, m.contentWidth())
+	
 	// Apply horizontal scrolling
 	for i, line := range visibleLines {
 		if m.xOffset < len(line) {
@@ -705,6 +962,9 @@ func (m model) View() string {
 		}
 	}
 	
+	// Show the table of contents on the right of the text
+	visibleLines = m.besideTOC(visibleLines, visibleHeight)
+	
 	result := strings.Join(visibleLines, "\n")
 	
 	// Calculate how many lines we've used so far
@@ -721,8 +981,8 @@ func (m model) View() string {
 			
 			// Apply search status colors if configured
 			if m.config.Colors.StatusBarText != "" {
-				if colorCode, err := hexToANSI(m.config.Colors.StatusBarText); err == nil {
-					searchStatusStyle = searchStatusStyle.Foreground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+				if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.StatusBarText); ok {
+					searchStatusStyle = searchStatusStyle.Foreground(color)
 				}
 			}
 			
@@ -811,7 +1071,7 @@ func (m model) extractLinkPositions(content string) []linkPosition {
 				// We need to strip ALL escape sequences from the portion before the link text
 				beforeLink := line[:match[4]] // Get everything before the link text starts
 				visibleBefore := stripAllEscapeSequences(beforeLink)
-				x := len(visibleBefore)
+				x := runewidth.StringWidth(visibleBefore)
 				
 				if f != nil {
 					fmt.Fprintf(f, "  Found link: url=%s, text=%q, visibleText=%q\n", url, text, visibleText)
@@ -824,7 +1084,7 @@ func (m model) extractLinkPositions(content string) []linkPosition {
 					text:   visibleText,
 					x:      x,
 					y:      y,
-					width:  len(visibleText),
+					width:  runewidth.StringWidth(visibleText),
 				})
 			}
 		}
@@ -859,39 +1119,95 @@ type linkPosition struct {
 	width int
 }
 
+// render renders the document for the current width and state
 func (m model) render() []byte {
+	rendered, _ := m.renderDocument()
+	return rendered
+}
+
+// renderDocument renders the document and indexes its headings
+func (m model) renderDocument() ([]byte, []heading) {
 	// Get options from config, plus required options
 	opts := m.config.GetMarkdownOptions()
 
-	// Calculate render width
+	// Place the text column according to the layout settings
+	layout := computeLayout(m.config.Layout, m.contentWidth())
+	
+	// Render width, left padding included
+	renderWidth := layout.padding + layout.width
+	
+	// Collapse details, draw mermaid diagrams and convert math, then process images, badges, alerts, footnotes and tables before rendering
+	processedMarkdown := processDetails(m.raw, m.detailsToggled)
+	processedMarkdown = processHeadings(processedMarkdown)
+	var diagrams []string
+	if !m.diagramSource {
+		processedMarkdown, diagrams = processMermaid(processedMarkdown)
+	}
+	processedMarkdown, formulas := processMath(processedMarkdown)
+	processedMarkdown = processEmoji(processedMarkdown, m.config.Emoji)
+	processedMarkdown = processHTML(processedMarkdown)
+	protocol := ""
+	if strings.Contains(processedMarkdown, "![") {
+		protocol = graphicsProtocol(m.config.Images)
+	}
+	processedMarkdown, images := processImages(processedMarkdown, m.config.Images, protocol)
+	processedMarkdown, badges := processBadges(processedMarkdown, m.config)
+	processedMarkdown = processAlerts(processedMarkdown)
+	processedMarkdown = processFootnotes(processedMarkdown)
+	processedMarkdown, tables := processTables(processedMarkdown)
+	renderTableCells(tables, m.config, m.hoveredURL, opts)
+	
+	installSyntaxHighlighter(m.config, processedMarkdown)
+	rendered := markdown.Render(processedMarkdown, renderWidth, layout.padding, opts...)
+	
+	// Label and number the code blocks, before the wrap of each line is recorded
+	rendered = labelCodeBlocks(rendered, findCodeBlocks(m.numberedSource(), processedMarkdown), m.config.LineNumbers, renderWidth, &m.config.Colors)
+	
 	// The markdown library includes both link text AND URL in line length calculations,
 	// but we convert to OSC 8 hyperlinks where only the link text is visible.
-	// So we render at a wider width to prevent unnecessary wrapping.
-	// Use 2x terminal width to give plenty of room for URLs
-	renderWidth := (m.width - padding) * 2
-	if renderWidth < 40 {
-		renderWidth = 40
-	}
-	
-	// Process badges before rendering
-	processedMarkdown := processBadges(m.raw, m.config)
+	// So paragraphs are joined back here and wrapped again after the links are converted.
+	rendered, wraps := unwrapParagraphs(rendered, renderWidth)
 	
-	rendered := markdown.Render(processedMarkdown, renderWidth, padding, opts...)
+	// Restyle the rendered elements with the theme colors
+	rendered = applyTheme(rendered, &m.config.Colors)
 	
 	// Add hyperlinks with underlines (pass hoveredURL for hover state)
 	rendered = addHyperlinks(rendered, processedMarkdown, m.config, m.hoveredURL)
 	
-	// Count lines
-	lineCount := 0
-	for _, b := range rendered {
-		if b == '\n' {
-			lineCount++
+	// Wrap paragraphs on their visible text
+	rendered = wrapParagraphs(rendered, wraps, renderWidth)
+	
+	// Lay out the tables, which are wrapped or scrolled on their own
+	rendered = insertTables(rendered, tables, renderWidth, m.config.Layout.Tables, m.tableOffsets, &m.config.Colors)
+	
+	// Put back the words of inline math and the badges, kept whole while wrapping
+	rendered = insertInlineMath(rendered, formulas)
+	rendered = insertBadges(rendered, badges)
+	
+	// Draw the keycaps of <kbd> tags, which keep their width
+	rendered = styleKeys(rendered, &m.config.Colors)
+	
+	// Put the diagrams, display math and images in place, past wrapping since they are laid out on their own
+	rendered = insertDiagrams(rendered, diagrams, m.config.Colors.GetANSIColor(m.config.Colors.CodeBlock))
+	rendered = insertMath(rendered, formulas, renderWidth, m.config.Colors.GetANSIColor(m.config.Colors.Italic))
+	rendered = insertImages(rendered, images, protocol, renderWidth, m.imageRows(), m.config.Colors.GetANSIColor(m.config.Colors.Italic))
+	
+	// Show the front matter above the document
+	if header := renderFrontMatter(m.meta, &m.config.Colors, layout.width, m.fullFrontMatter); header != nil {
+		pad := strings.Repeat(" ", layout.padding)
+		for i, line := range header {
+			if line != "" {
+				header[i] = pad + line
+			}
 		}
+		rendered = append([]byte(strings.Join(header, "\n")+"\n"), rendered...)
 	}
 	
-	// Update the model's line count (this is a bit of a hack since we can't modify m in this method)
-	// We'll handle this in the View method instead
-	return rendered
+	// Center the column
+	rendered = layout.indent(rendered)
+	
+	// Find the headings, now that their lines are final
+	return indexHeadings(rendered)
 }
 
 func (m model) renderHelp(backgroundContent []byte) string {
@@ -1047,6 +1363,9 @@ func (m model) renderNormalView() string {
 	
 	visibleLines := lines[startLine:endLine]
 	
+	// Images would be drawn over the help box
+	visibleLines = hideImages(visibleLines)
+	
 	// Apply horizontal scrolling
 	for i, line := range visibleLines {
 		if m.xOffset < len(line) {
@@ -1056,6 +1375,9 @@ func (m model) renderNormalView() string {
 		}
 	}
 	
+	// Show the table of contents on the right of the text
+	visibleLines = m.besideTOC(visibleLines, visibleHeight)
+	
 	result := strings.Join(visibleLines, "\n")
 	
 	// Calculate how many lines we've used so far
@@ -1072,8 +1394,8 @@ func (m model) renderNormalView() string {
 			
 			// Apply search status colors if configured
 			if m.config.Colors.StatusBarText != "" {
-				if colorCode, err := hexToANSI(m.config.Colors.StatusBarText); err == nil {
-					searchStatusStyle = searchStatusStyle.Foreground(lipgloss.Color(fmt.Sprintf("%d", colorCode)))
+				if color, ok := m.config.Colors.GetLipglossColor(m.config.Colors.StatusBarText); ok {
+					searchStatusStyle = searchStatusStyle.Foreground(color)
 				}
 			}
 			
@@ -1265,6 +1587,12 @@ func (m model) buildHelpContent() string {
 	sb.WriteString(fmt.Sprintf("  %-20s Page down\n", formatKeys(m.config.Keybindings.PageDown)))
 	sb.WriteString(fmt.Sprintf("  %-20s Go to top\n", formatKeys(m.config.Keybindings.GoToTop)))
 	sb.WriteString(fmt.Sprintf("  %-20s Go to bottom\n", formatKeys(m.config.Keybindings.GoToBottom)))
+	sb.WriteString(fmt.Sprintf("  %-20s Next heading\n", formatKeys(m.config.Keybindings.NextHeading)))
+	sb.WriteString(fmt.Sprintf("  %-20s Previous heading\n", formatKeys(m.config.Keybindings.PrevHeading)))
+	sb.WriteString(fmt.Sprintf("  %-20s Next heading of the same level\n", formatKeys(m.config.Keybindings.NextSiblingHeading)))
+	sb.WriteString(fmt.Sprintf("  %-20s Parent heading\n", formatKeys(m.config.Keybindings.ParentHeading)))
+	sb.WriteString(fmt.Sprintf("  %-20s Follow footnote\n", formatKeys(m.config.Keybindings.FollowFootnote)))
+	sb.WriteString(fmt.Sprintf("  %-20s Back from footnote\n", formatKeys(m.config.Keybindings.FootnoteBack)))
 	sb.WriteString("\n")
 
 	// Search section
@@ -1282,6 +1610,12 @@ func (m model) buildHelpContent() string {
 	sb.WriteString(fmt.Sprintf("  %-20s Show this help\n", formatKeys(m.config.Keybindings.ShowHelp)))
 	sb.WriteString(fmt.Sprintf("  %-20s Quit\n", formatKeys(m.config.Keybindings.Quit)))
 	sb.WriteString(fmt.Sprintf("  %-20s Toggle mouse mode\n", formatKeys(m.config.Keybindings.ToggleMouse)))
+	sb.WriteString(fmt.Sprintf("  %-20s Next theme\n", formatKeys(m.config.Keybindings.CycleTheme)))
+	sb.WriteString(fmt.Sprintf("  %-20s Toggle diagrams/source\n", formatKeys(m.config.Keybindings.ToggleDiagrams)))
+	sb.WriteString(fmt.Sprintf("  %-20s Toggle all front matter fields\n", formatKeys(m.config.Keybindings.ToggleFrontMatter)))
+	sb.WriteString(fmt.Sprintf("  %-20s Expand/collapse details\n", formatKeys(m.config.Keybindings.ToggleDetails)))
+	sb.WriteString(fmt.Sprintf("  %-20s Copy code block\n", formatKeys(m.config.Keybindings.CopyCodeBlock)))
+	sb.WriteString(fmt.Sprintf("  %-20s Toggle table of contents\n", formatKeys(m.config.Keybindings.ToggleTOC)))
 	sb.WriteString("\n")
 
 	// Notes section
@@ -1299,6 +1633,47 @@ func (m model) buildHelpContent() string {
 	return sb.String()
 }
 
+// cycleTheme switches to the next theme, keeping the scroll position.
+// Themes that fail to load are skipped.
+func (m model) cycleTheme() model {
+	name := m.config.Theme
+	for range ThemeNames() {
+		name = nextThemeName(name)
+		if err := m.config.UseTheme(name); err == nil {
+			break
+		}
+	}
+	
+	installRendererPalette(&m.config.Colors)
+	m.styles = newModelStyles(m.config)
+	return m.refresh()
+}
+
+// refresh renders the document again, keeping the search and links in sync
+func (m model) refresh() model {
+	m.renderedContent, m.headings = m.renderDocument()
+	m.lines = strings.Count(string(m.renderedContent), "\n")
+	
+	// Match positions include escape sequences, which differ between renders
+	if m.search.term != "" {
+		current := m.search.currentIndex
+		m.search.SetTerm(m.search.term, string(m.renderedContent))
+		if current < len(m.search.matches) {
+			m.search.currentIndex = current
+		}
+	}
+	
+	return m.updateLinkPositions()
+}
+
+// themeName returns the name of the active theme for the status bar
+func (m model) themeName() string {
+	if m.config.Theme == "" {
+		return "custom"
+	}
+	return m.config.Theme
+}
+
 func (m model) startSearch() model {
 	m.searchActive = true
 	m.searchInput = ""
@@ -1450,12 +1825,19 @@ func (m model) scrollDown() model {
 }
 
 func (m model) scrollLeft() model {
+	// A wide table on screen scrolls on its own
+	if url, ok := m.visibleTableScroll("left"); ok {
+		return m.scrollTable(url)
+	}
 	m.xOffset -= 1
 	m.xOffset = max(m.xOffset, 0)
 	return m.updateLinkPositions()
 }
 
 func (m model) scrollRight() model {
+	if url, ok := m.visibleTableScroll("right"); ok {
+		return m.scrollTable(url)
+	}
 	m.xOffset += 1
 	return m.updateLinkPositions()
 }
@@ -1484,6 +1866,53 @@ func (m model) goToBottom() model {
 	return m.updateLinkPositions()
 }
 
+// goToLine puts line n of the document, counting from 1, at the top of the
+// screen
+func (m model) goToLine(n int) model {
+	m.yOffset = max(min(n-1, m.lines-m.height+1), 0)
+	return m.updateLinkPositions()
+}
+
+// followFootnote scrolls to the other end of a footnote link and remembers
+// the current position for footnoteBack
+func (m model) followFootnote(url string) model {
+	line := footnoteTargetLine(m.renderedContent, url)
+	if line < 0 {
+		return m
+	}
+	m.footnoteReturns = append(m.footnoteReturns, m.yOffset)
+	m = m.scrollToLine(line)
+	return m.updateLinkPositions()
+}
+
+// followVisibleFootnote follows the first footnote reference on screen, or
+// the first back link if there is none
+func (m model) followVisibleFootnote() model {
+	backLink := ""
+	for _, link := range m.linkPositions {
+		if strings.HasPrefix(link.url, footnoteURL) {
+			return m.followFootnote(link.url)
+		}
+		if backLink == "" && strings.HasPrefix(link.url, footnoteRefURL) {
+			backLink = link.url
+		}
+	}
+	if backLink != "" {
+		return m.followFootnote(backLink)
+	}
+	return m
+}
+
+// footnoteBack returns to the position the last footnote link was followed from
+func (m model) footnoteBack() model {
+	if len(m.footnoteReturns) == 0 {
+		return m
+	}
+	m.yOffset = m.footnoteReturns[len(m.footnoteReturns)-1]
+	m.footnoteReturns = m.footnoteReturns[:len(m.footnoteReturns)-1]
+	return m.updateLinkPositions()
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// Code blocks get a label line on top with their number, their language and
+// a "copy" link to "#code-<n>", n counting the code blocks of the document
+// from 1, leaving out the mermaid blocks drawn as diagrams. Following the
+// link, or the copy_code_block key, puts the source of the block as written
+// in the document on the clipboard with OSC 52, which terminals honor over
+// SSH too. With line_numbers set, the lines of code are numbered as well.
+
+const codeBlockURL = "#code-"
+
+var codeBlockURLPattern = regexp.MustCompile(`^#code-(\d+)$`)
+
+// clipboardOutput receives the OSC 52 sequences
+var clipboardOutput io.Writer = os.Stdout
+
+// codeBlockCopiedMsg reports the copy of code block n to the clipboard
+type codeBlockCopiedMsg struct {
+	n     int
+	lines int
+	err   error
+}
+
+// codeBlock is a code block of the processed markdown
+type codeBlock struct {
+	// n is the number of the block in the document, 0 for the blocks the
+	// document doesn't hold as is
+	n        int
+	language string
+	literal  string
+}
+
+// numberedSource returns the markdown whose code blocks are numbered: the
+// document, without the mermaid blocks drawn as diagrams
+func (m model) numberedSource() string {
+	if m.diagramSource {
+		return m.raw
+	}
+	source, _ := processMermaid(m.raw)
+	return source
+}
+
+// findCodeBlocks returns the code blocks of processed in document order,
+// numbered after the blocks of numbered holding the same code
+func findCodeBlocks(numbered, processed string) []codeBlock {
+	rawBlocks := codeBlocks(numbered)
+	next := 0
+	var blocks []codeBlock
+	for _, block := range codeBlocks(processed) {
+		b := codeBlock{literal: string(block.Literal)}
+		if fields := strings.Fields(string(block.Info)); len(fields) > 0 {
+			b.language = fields[0]
+		}
+		for i := next; i < len(rawBlocks); i++ {
+			if string(rawBlocks[i].Literal) == b.literal {
+				b.n = i + 1
+				next = i + 1
+				break
+			}
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// labelCodeBlocks adds the label line of the code blocks rendered by
+// go-term-markdown, and numbers their lines when lineNumbers is set. Lines
+// the numbers push past width are wrapped.
+func labelCodeBlocks(rendered []byte, blocks []codeBlock, lineNumbers bool, width int, colors *ColorConfig) []byte {
+	style := codeLabelStyle(colors)
+	lines := strings.Split(string(rendered), "\n")
+	var out []string
+	next := 0
+	for start := 0; start < len(lines); {
+		first := parseThemedLine(lines[start])
+		if !first.code {
+			out = append(out, lines[start])
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(lines) {
+			l := parseThemedLine(lines[end])
+			if !l.code || l.prefixKey() != first.prefixKey() {
+				break
+			}
+			end++
+		}
+
+		prefix := lines[start][:len(lines[start])-len(first.body)]
+		var body []string
+		for _, line := range lines[start:end] {
+			body = append(body, line[len(prefix):])
+		}
+
+		// Blocks are matched on their code, in case one wasn't found here
+		var block codeBlock
+		code := nonSpace(stripANSI(strings.Join(body, "")))
+		for i := next; i < len(blocks); i++ {
+			if nonSpace(blocks[i].literal) == code {
+				block = blocks[i]
+				next = i + 1
+				break
+			}
+		}
+
+		if lineNumbers && block.literal != "" {
+			available := width - runewidth.StringWidth(stripANSI(prefix))
+			body = numberCodeLines(body, block.literal, available, style)
+		}
+
+		if block.n > 0 {
+			out = append(out, prefix+codeBlockLabel(block, body, style))
+		}
+		for _, line := range body {
+			out = append(out, prefix+line)
+		}
+		start = end
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+// codeLabelStyle returns the style of labels and line numbers, the code
+// block color faded into the code block background
+func codeLabelStyle(c *ColorConfig) string {
+	fg, err := parseHexColor(c.CodeBlock)
+	if err != nil {
+		return c.GetANSIColor(c.CodeBlock)
+	}
+	bg, err := parseHexColor(c.CodeBlockBg)
+	if err != nil {
+		return c.GetANSIColor(c.CodeBlock)
+	}
+	return c.GetANSIColor(fg.blend(bg, 0.45).hex())
+}
+
+// codeBlockLabel returns the label of a code block: its number and language,
+// and a copy link on the right of the block
+func codeBlockLabel(block codeBlock, body []string, style string) string {
+	label := strings.TrimSpace(fmt.Sprintf("#%d %s", block.n, block.language))
+	width := 0
+	for _, line := range body {
+		width = max(width, runewidth.StringWidth(stripANSI(line)))
+	}
+	gap := max(width-runewidth.StringWidth(label)-len("copy"), 2)
+	link := fmt.Sprintf("\x1b[4m\x1b]8;;%s%d\x1b\\copy\x1b]8;;\x1b\\\x1b[24m", codeBlockURL, block.n)
+	return paletteFunc(style)(label + strings.Repeat(" ", gap) + link)
+}
+
+// numberCodeLines puts line numbers in front of the rendered lines of a
+// block of code. The renderer wraps long lines, so the lines of literal are
+// followed through their non-space characters, and the continuation lines
+// get no number.
+func numberCodeLines(body []string, literal string, available int, style string) []string {
+	var counts []int
+	for _, line := range strings.Split(strings.TrimRight(literal, "\n"), "\n") {
+		counts = append(counts, nonSpaceCount(line))
+	}
+	digits := len(strconv.Itoa(len(counts)))
+	blank := strings.Repeat(" ", digits+1)
+	available = max(available-digits-1, 1)
+
+	var out []string
+	j, consumed := 0, 0
+	for _, line := range body {
+		number := blank
+		if consumed == 0 && j < len(counts) {
+			number = paletteFunc(style)(fmt.Sprintf("%*d", digits, j+1)) + " "
+		}
+		if j < len(counts) {
+			consumed += nonSpaceCount(stripANSI(line))
+			if consumed >= counts[j] {
+				j++
+				consumed = 0
+			}
+		}
+
+		for runewidth.StringWidth(stripANSI(line)) > available {
+			head, tail := cutColumns(line, available)
+			open := activeStyle(head)
+			if open != "" {
+				head += sgrReset
+			}
+			out = append(out, number+head)
+			number = blank
+			line = open + tail
+		}
+		out = append(out, number+line)
+	}
+	return out
+}
+
+// nonSpace returns s without its white space
+func nonSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// nonSpaceCount counts the characters of s that aren't white space
+func nonSpaceCount(s string) int {
+	return utf8.RuneCountInString(nonSpace(s))
+}
+
+// activeStyle returns the SGR sequences still in effect at the end of s
+func activeStyle(s string) string {
+	style := ""
+	for _, token := range tokenize(s) {
+		switch {
+		case token == sgrReset || token == "\x1b[m":
+			style = ""
+		case strings.HasPrefix(token, "\x1b[") && strings.HasSuffix(token, "m"):
+			style += token
+		}
+	}
+	return style
+}
+
+// isCodeBlockURL tells whether url is the copy link of a code block
+func isCodeBlockURL(url string) bool {
+	return codeBlockURLPattern.MatchString(url)
+}
+
+// copyCodeBlock returns the command putting the source of the code block
+// of a copy link on the clipboard, as written in the document. The sequence
+// goes out from a command rather than in the middle of an update.
+func (m model) copyCodeBlock(url string) tea.Cmd {
+	match := codeBlockURLPattern.FindStringSubmatch(url)
+	if match == nil {
+		return nil
+	}
+	n, _ := strconv.Atoi(match[1])
+	blocks := codeBlocks(m.numberedSource())
+	if n < 1 || n > len(blocks) {
+		return nil
+	}
+
+	source := strings.TrimRight(string(blocks[n-1].Literal), "\n")
+	return func() tea.Msg {
+		return codeBlockCopiedMsg{n: n, lines: strings.Count(source, "\n") + 1, err: copyToClipboard(source)}
+	}
+}
+
+// codeBlockCopied tells how the copy of a code block went
+func (m model) codeBlockCopied(msg codeBlockCopiedMsg) model {
+	if msg.err != nil {
+		m.notice = fmt.Sprintf("Couldn't copy code block %d: %v", msg.n, msg.err)
+		return m
+	}
+	plural := "s"
+	if msg.lines == 1 {
+		plural = ""
+	}
+	m.notice = fmt.Sprintf("Copied code block %d (%d line%s)", msg.n, msg.lines, plural)
+	return m
+}
+
+// copyVisibleCodeBlock copies the first code block whose label is on
+// screen, or else the block running through the top of the screen
+func (m model) copyVisibleCodeBlock() (model, tea.Cmd) {
+	for _, link := range m.linkPositions {
+		if isCodeBlockURL(link.url) {
+			return m, m.copyCodeBlock(link.url)
+		}
+	}
+
+	// Code blocks have no blank lines, the label is above the first one
+	target := "\x1b]8;;" + codeBlockURL
+	lines := strings.Split(string(m.renderedContent), "\n")
+	for i := min(m.yOffset, len(lines)) - 1; i >= 0; i-- {
+		if strings.TrimSpace(stripANSI(lines[i])) == "" {
+			break
+		}
+		start := strings.Index(lines[i], target)
+		if start < 0 {
+			continue
+		}
+		url := lines[i][start+len("\x1b]8;;"):]
+		url = url[:strings.Index(url+"\x1b", "\x1b")]
+		return m, m.copyCodeBlock(url)
+	}
+	m.notice = "No code block on screen"
+	return m, nil
+}
+
+// copyToClipboard sets the system clipboard with OSC 52, through tmux and
+// screen when bleamd runs inside them
+func copyToClipboard(s string) error {
+	seq := osc52.New(s)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+	_, err := seq.WriteTo(clipboardOutput)
+	return err
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFindCodeBlocks(t *testing.T) {
+	raw := "```sh\necho a\n```\n\n<details><summary>More</summary>\n\n```go\nb()\n```\n</details>\n\n    c\n"
+	expected := []codeBlock{
+		{n: 1, language: "sh", literal: "echo a\n"},
+		{n: 3, literal: "c\n"},
+	}
+	if got := findCodeBlocks(raw, processDetails(raw, nil)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("findCodeBlocks() = %+v, expected %+v", got, expected)
+	}
+}
+
+func TestNumberCodeBlocksAfterDiagrams(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+	clipboardOutput = &bytes.Buffer{}
+	t.Cleanup(func() { clipboardOutput = os.Stdout })
+
+	raw := "```mermaid\ngraph LR\n  A --> B\n```\n\n```sh\necho a\n```\n"
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 80
+	m.height = 20
+	m = m.refresh()
+
+	rendered := stripANSI(string(m.renderedContent))
+	if !strings.Contains(rendered, "#1 sh") {
+		t.Errorf("Expected the block after the diagram to be #1, got:\n%s", rendered)
+	}
+	if cmd := m.copyCodeBlock(codeBlockURL + "1"); cmd == nil {
+		t.Fatal("Expected a command copying block 1")
+	} else if msg := cmd().(codeBlockCopiedMsg); msg.lines != 1 {
+		t.Errorf("Copied %d lines, expected the single line of the sh block", msg.lines)
+	}
+
+	// With the diagrams shown as source, the mermaid block is numbered too
+	m.diagramSource = true
+	m = m.refresh()
+	if rendered := stripANSI(string(m.renderedContent)); !strings.Contains(rendered, "#1 mermaid") || !strings.Contains(rendered, "#2 sh") {
+		t.Errorf("Expected the mermaid block to be #1 and the sh block #2, got:\n%s", rendered)
+	}
+}
+
+func TestNumberCodeLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      []string
+		literal   string
+		available int
+		expected  []string
+	}{
+		{
+			name:      "wrapped by the renderer",
+			body:      []string{"one", "two", "", "three"},
+			literal:   "one two\n\nthree\n",
+			available: 20,
+			expected:  []string{"1 one", "  two", "2 ", "3 three"},
+		},
+		{
+			name:      "wrapped for the numbers",
+			body:      []string{"abcdefgh", "i"},
+			literal:   "abcdefgh\ni",
+			available: 6,
+			expected:  []string{"1 abcd", "  efgh", "2 i"},
+		},
+		{
+			name:      "styles reopened",
+			body:      []string{"\x1b[1mabcdef\x1b[0m"},
+			literal:   "abcdef",
+			available: 5,
+			expected:  []string{"1 \x1b[1mabc\x1b[0m", "  \x1b[1mdef\x1b[0m"},
+		},
+		{
+			name:      "number width",
+			body:      strings.Split(strings.Repeat("x\n", 10), "\n")[:10],
+			literal:   strings.Repeat("x\n", 10),
+			available: 20,
+			expected:  []string{" 1 x", " 2 x", " 3 x", " 4 x", " 5 x", " 6 x", " 7 x", " 8 x", " 9 x", "10 x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := numberCodeLines(tt.body, tt.literal, tt.available, ""); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("numberCodeLines() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCopyCodeBlock(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+	var clipboard bytes.Buffer
+	clipboardOutput = &clipboard
+	t.Cleanup(func() { clipboardOutput = os.Stdout })
+
+	raw := "Intro.\n\n```sh\necho \"hello\"\n```\n\n" + strings.Repeat("Filler.\n\n", 20) + "```go\nfunc main() {\n\tprintln(1)\n}\n```\n"
+	config := DefaultConfig()
+	config.LineNumbers = true
+	m := newModel([]byte(raw), config)
+	m.width = 80
+	m.height = 10
+	m = m.refresh()
+
+	rendered := stripANSI(string(m.renderedContent))
+	for _, expected := range []string{"#1 sh", "1 echo \"hello\"", "#2 go", "2     println(1)"} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected %q in the rendering, got:\n%s", expected, rendered)
+		}
+	}
+
+	// The sequence is written by the command, not during the update
+	copied := func(updated tea.Model, cmd tea.Cmd) {
+		m = updated.(model)
+		if clipboard.Len() > 0 {
+			t.Errorf("Expected nothing written before the command runs, got %q", clipboard.String())
+		}
+		if cmd == nil {
+			t.Fatal("Expected a command copying the block")
+		}
+		updated, _ = m.update(cmd())
+		m = updated.(model)
+	}
+
+	// Copy the block on screen from the keyboard
+	copied(m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")}))
+	if got, expected := clipboard.String(), osc52.New("echo \"hello\"").String(); got != expected {
+		t.Errorf("Copied %q, expected %q", got, expected)
+	}
+	if m.notice != "Copied code block 1 (1 line)" {
+		t.Errorf("notice = %q, expected %q", m.notice, "Copied code block 1 (1 line)")
+	}
+
+	// Copy the other block by clicking its label
+	m = m.goToBottom()
+	var copyLink linkPosition
+	for _, link := range m.linkPositions {
+		if isCodeBlockURL(link.url) {
+			copyLink = link
+		}
+	}
+	if copyLink.url != "#code-2" {
+		t.Fatalf("Expected the copy link of the second block on screen, got %q", copyLink.url)
+	}
+	clipboard.Reset()
+	copied(m.handleMouseMsg(tea.MouseMsg{X: copyLink.x, Y: copyLink.y, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft}))
+	if got, expected := clipboard.String(), osc52.New("func main() {\n\tprintln(1)\n}").String(); got != expected {
+		t.Errorf("Copied %q, expected %q", got, expected)
+	}
+	if m.notice != "Copied code block 2 (3 lines)" {
+		t.Errorf("notice = %q, expected %q", m.notice, "Copied code block 2 (3 lines)")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fatih/color"
+	"github.com/muesli/termenv"
+)
+
+// ColorProfile is the set of colors the terminal can display
+type ColorProfile int
+
+const (
+	// ProfileNoColor disables all colors
+	ProfileNoColor ColorProfile = iota
+	// ProfileANSI16 uses the 16 basic terminal colors
+	ProfileANSI16
+	// ProfileANSI256 uses the xterm 256 color palette
+	ProfileANSI256
+	// ProfileTrueColor uses 24-bit colors
+	ProfileTrueColor
+)
+
+// colorProfile is the profile used for every escape sequence bleamd emits
+var colorProfile = detectColorProfile()
+
+// String returns the name of the profile as accepted by --color
+func (p ColorProfile) String() string {
+	switch p {
+	case ProfileNoColor:
+		return "never"
+	case ProfileANSI16:
+		return "16"
+	case ProfileANSI256:
+		return "256"
+	case ProfileTrueColor:
+		return "truecolor"
+	}
+	return "unknown"
+}
+
+// parseColorMode parses the value of the --color option
+func parseColorMode(mode string) (ColorProfile, error) {
+	switch strings.ToLower(mode) {
+	case "auto", "":
+		return detectColorProfile(), nil
+	case "truecolor", "24bit":
+		return ProfileTrueColor, nil
+	case "256":
+		return ProfileANSI256, nil
+	case "16":
+		return ProfileANSI16, nil
+	case "never", "none", "off":
+		return ProfileNoColor, nil
+	}
+	return ProfileNoColor, fmt.Errorf("invalid color mode %q (expected auto, truecolor, 256, 16 or never)", mode)
+}
+
+// detectColorProfile guesses the terminal capabilities from the environment
+func detectColorProfile() ColorProfile {
+	if os.Getenv("NO_COLOR") != "" {
+		return ProfileNoColor
+	}
+
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return ProfileTrueColor
+	}
+
+	term := strings.ToLower(os.Getenv("TERM"))
+	switch {
+	case term == "dumb":
+		return ProfileNoColor
+	case strings.Contains(term, "direct"), strings.Contains(term, "truecolor"),
+		strings.Contains(term, "kitty"), strings.Contains(term, "wezterm"),
+		strings.Contains(term, "alacritty"), strings.Contains(term, "ghostty"):
+		return ProfileTrueColor
+	case strings.Contains(term, "256color"):
+		return ProfileANSI256
+	case os.Getenv("WT_SESSION") != "":
+		// Windows Terminal doesn't set TERM but supports 24-bit colors
+		return ProfileTrueColor
+	}
+	return ProfileANSI16
+}
+
+// setColorProfile switches bleamd, lipgloss and the markdown renderer to the
+// given profile
+func setColorProfile(p ColorProfile) {
+	colorProfile = p
+
+	switch p {
+	case ProfileTrueColor:
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	case ProfileANSI256:
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	case ProfileANSI16:
+		lipgloss.SetColorProfile(termenv.ANSI)
+	default:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	// go-term-markdown colors headings, quotes and code through fatih/color
+	color.NoColor = p == ProfileNoColor
+}
+
+// rgbColor is a parsed hex color
+type rgbColor struct {
+	r, g, b int
+}
+
+// parseHexColor parses a #rrggbb color
+func parseHexColor(hex string) (rgbColor, error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return rgbColor{}, fmt.Errorf("invalid hex color: %s", hex)
+	}
+
+	var c rgbColor
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &c.r, &c.g, &c.b); err != nil {
+		return rgbColor{}, fmt.Errorf("invalid hex color: %s", hex)
+	}
+	return c, nil
+}
+
+// distance returns the squared euclidean distance between two colors
+func (c rgbColor) distance(o rgbColor) int {
+	dr, dg, db := c.r-o.r, c.g-o.g, c.b-o.b
+	return dr*dr + dg*dg + db*db
+}
+
+// cubeLevels are the channel values of the 6x6x6 color cube
+var cubeLevels = []int{0, 95, 135, 175, 215, 255}
+
+// nearestANSI256 returns the closest color of the 256 color palette,
+// choosing between the color cube and the grayscale ramp
+func nearestANSI256(c rgbColor) int {
+	nearestLevel := func(v int) int {
+		best := 0
+		for i, level := range cubeLevels {
+			if abs(v-level) < abs(v-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	ri, gi, bi := nearestLevel(c.r), nearestLevel(c.g), nearestLevel(c.b)
+	cube := rgbColor{cubeLevels[ri], cubeLevels[gi], cubeLevels[bi]}
+	cubeIndex := 16 + 36*ri + 6*gi + bi
+
+	// Grayscale ramp: 232-255 map to 8, 18, ..., 238
+	avg := (c.r + c.g + c.b) / 3
+	grayStep := min(max((avg-8+5)/10, 0), 23)
+	grayValue := 8 + grayStep*10
+	gray := rgbColor{grayValue, grayValue, grayValue}
+
+	if c.distance(gray) < c.distance(cube) {
+		return 232 + grayStep
+	}
+	return cubeIndex
+}
+
+// ansi16Palette holds the usual xterm values of the 16 basic colors
+var ansi16Palette = []rgbColor{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// nearestANSI16 returns the closest of the 16 basic colors
+func nearestANSI16(c rgbColor) int {
+	best := 0
+	for i, p := range ansi16Palette {
+		if c.distance(p) < c.distance(ansi16Palette[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// colorSequence returns the SGR sequence selecting hex as a foreground (38),
+// background (48) or underline (58) color in the active profile
+func colorSequence(hex string, ground int) (string, error) {
+	c, err := parseHexColor(hex)
+	if err != nil {
+		return "", err
+	}
+
+	switch colorProfile {
+	case ProfileTrueColor:
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", ground, c.r, c.g, c.b), nil
+	case ProfileANSI256:
+		return fmt.Sprintf("\x1b[%d;5;%dm", ground, nearestANSI256(c)), nil
+	case ProfileANSI16:
+		index := nearestANSI16(c)
+		if ground == 58 {
+			// There is no basic SGR code for underline colors
+			return fmt.Sprintf("\x1b[58;5;%dm", index), nil
+		}
+		// 30-37/40-47 for normal colors, 90-97/100-107 for bright ones
+		base := ground - 8
+		if index >= 8 {
+			base += 60
+			index -= 8
+		}
+		return fmt.Sprintf("\x1b[%dm", base+index), nil
+	}
+	return "", nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
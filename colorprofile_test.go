@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// useColorProfile switches to a color profile for the duration of a test
+func useColorProfile(t *testing.T, p ColorProfile) {
+	t.Helper()
+
+	previous := colorProfile
+	noColor := color.NoColor
+	setColorProfile(p)
+	t.Cleanup(func() {
+		setColorProfile(previous)
+		color.NoColor = noColor
+	})
+}
+
+func TestDetectColorProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		noColor   bool
+		colorTerm string
+		term      string
+		expected  ColorProfile
+	}{
+		{name: "NO_COLOR wins", noColor: true, colorTerm: "truecolor", term: "xterm-256color", expected: ProfileNoColor},
+		{name: "COLORTERM truecolor", colorTerm: "truecolor", term: "xterm", expected: ProfileTrueColor},
+		{name: "COLORTERM 24bit", colorTerm: "24bit", term: "xterm", expected: ProfileTrueColor},
+		{name: "direct color TERM", term: "xterm-direct", expected: ProfileTrueColor},
+		{name: "kitty", term: "xterm-kitty", expected: ProfileTrueColor},
+		{name: "256 color TERM", term: "screen-256color", expected: ProfileANSI256},
+		{name: "basic xterm", term: "xterm", expected: ProfileANSI16},
+		{name: "dumb terminal", term: "dumb", expected: ProfileNoColor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noColor := ""
+			if tt.noColor {
+				noColor = "1"
+			}
+			t.Setenv("NO_COLOR", noColor)
+			t.Setenv("COLORTERM", tt.colorTerm)
+			t.Setenv("TERM", tt.term)
+			t.Setenv("WT_SESSION", "")
+
+			if got := detectColorProfile(); got != tt.expected {
+				t.Errorf("Expected profile %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	tests := map[string]ColorProfile{
+		"truecolor": ProfileTrueColor,
+		"256":       ProfileANSI256,
+		"16":        ProfileANSI16,
+		"never":     ProfileNoColor,
+	}
+
+	for mode, expected := range tests {
+		got, err := parseColorMode(mode)
+		if err != nil {
+			t.Errorf("parseColorMode(%q) failed: %v", mode, err)
+		}
+		if got != expected {
+			t.Errorf("parseColorMode(%q): expected %s, got %s", mode, expected, got)
+		}
+	}
+
+	if _, err := parseColorMode("rainbow"); err == nil {
+		t.Error("Expected an error for an unknown color mode")
+	}
+}
+
+func TestColorSequence(t *testing.T) {
+	tests := []struct {
+		profile  ColorProfile
+		hex      string
+		ground   int
+		expected string
+	}{
+		{ProfileTrueColor, "#bd93f9", 38, "\x1b[38;2;189;147;249m"},
+		{ProfileTrueColor, "#282a36", 48, "\x1b[48;2;40;42;54m"},
+		{ProfileANSI256, "#ff0000", 38, "\x1b[38;5;196m"},
+		{ProfileANSI256, "#808080", 38, "\x1b[38;5;244m"},
+		{ProfileANSI256, "#5f87d7", 48, "\x1b[48;5;68m"},
+		{ProfileANSI16, "#ff0000", 38, "\x1b[91m"},
+		{ProfileANSI16, "#cd0000", 48, "\x1b[41m"},
+		{ProfileANSI16, "#000000", 38, "\x1b[30m"},
+		{ProfileNoColor, "#ff0000", 38, ""},
+	}
+
+	for _, tt := range tests {
+		useColorProfile(t, tt.profile)
+		got, err := colorSequence(tt.hex, tt.ground)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s %s: expected %q, got %q", tt.profile, tt.hex, tt.expected, got)
+		}
+	}
+
+	if _, err := colorSequence("#12345", 38); err == nil {
+		t.Error("Expected an error for an invalid hex color")
+	}
+}
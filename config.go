@@ -6,16 +6,46 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
+	"reflect"
 	"strings"
 
 	"github.com/MichaelMure/go-term-markdown"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // Config holds the configuration for bleamd
 type Config struct {
+	// Theme names a built-in theme the colors below are layered on top of
+	Theme       string           `json:"theme,omitempty"`
+	// Extends names a theme or theme file to inherit from, instead of Theme
+	Extends     string           `json:"extends,omitempty"`
+	// LightTheme and DarkTheme take the place of Theme on a light or dark terminal
+	LightTheme  string           `json:"light_theme,omitempty"`
+	DarkTheme   string           `json:"dark_theme,omitempty"`
+	// SyntaxStyle is the chroma style of code blocks, or "theme" for a
+	// palette derived from the code block colors
+	SyntaxStyle string           `json:"syntax_style,omitempty"`
+	// SyntaxStyles overrides SyntaxStyle per language, e.g. {"go": "monokai"}
+	SyntaxStyles map[string]string `json:"syntax_styles,omitempty"`
+	// Emoji is how :shortcodes: are shown: "unicode", "ascii" or "off"
+	Emoji       string           `json:"emoji,omitempty"`
+	// Images is how images are drawn: "auto", "protocol", "blocks",
+	// "alt-text" or "off"
+	Images      string           `json:"images,omitempty"`
+	// LineNumbers numbers the lines of code blocks
+	LineNumbers bool             `json:"line_numbers,omitempty"`
+	// KeyTimeout is how long, in milliseconds, a key sequence waits for
+	// its next key
+	KeyTimeout  int              `json:"key_timeout,omitempty"`
 	Colors     ColorConfig     `json:"colors"`
 	Keybindings KeybindingConfig `json:"keybindings"`
+	Layout     LayoutConfig    `json:"layout"`
+	
+	// themeSource is the key the theme was picked from, shown by --config-path
+	themeSource string
+	// sources maps "syntax_style" and "<section>.<key>" to the file the
+	// value was read from
+	sources map[string]string
 }
 
 // KeybindingConfig holds custom keybinding settings
@@ -29,6 +59,12 @@ type KeybindingConfig struct {
 	PageDown       []string `json:"page_down"`
 	GoToTop        []string `json:"go_to_top"`
 	GoToBottom     []string `json:"go_to_bottom"`
+	FollowFootnote []string `json:"follow_footnote"`
+	FootnoteBack   []string `json:"footnote_back"`
+	NextHeading    []string `json:"next_heading"`
+	PrevHeading    []string `json:"prev_heading"`
+	NextSiblingHeading []string `json:"next_sibling_heading"`
+	ParentHeading  []string `json:"parent_heading"`
 	
 	// Search keys
 	StartSearch    []string `json:"start_search"`
@@ -40,6 +76,28 @@ type KeybindingConfig struct {
 	Quit           []string `json:"quit"`
 	ShowHelp       []string `json:"show_help"`
 	ToggleMouse    []string `json:"toggle_mouse"`
+	CycleTheme     []string `json:"cycle_theme"`
+	ToggleDiagrams []string `json:"toggle_diagrams"`
+	ToggleFrontMatter []string `json:"toggle_front_matter"`
+	ToggleDetails  []string `json:"toggle_details"`
+	CopyCodeBlock  []string `json:"copy_code_block"`
+	ToggleTOC      []string `json:"toggle_toc"`
+}
+
+// LayoutConfig holds the placement of the text column in the terminal
+type LayoutConfig struct {
+	// MaxWidth caps the width of the text, 0 for no limit
+	MaxWidth       int    `json:"max_width"`
+	// Padding is the number of blank columns on each side of the text
+	Padding        int    `json:"padding"`
+	// Align is "left" or "center"
+	Align          string `json:"align"`
+	// Tables is "wrap" to wrap the cells of wide tables, or "scroll" to
+	// scroll them on their own
+	Tables         string `json:"tables"`
+	// TOCWidth is the width of the table of contents pane, at most half
+	// of the terminal
+	TOCWidth       int    `json:"toc_width"`
 }
 
 // ColorConfig holds color settings for markdown elements
@@ -75,6 +133,13 @@ type ColorConfig struct {
 	TableRow       string `json:"table_row"`
 	TableBorder    string `json:"table_border"`
 	
+	// GitHub alerts
+	AlertNote      string `json:"alert_note"`
+	AlertTip       string `json:"alert_tip"`
+	AlertImportant string `json:"alert_important"`
+	AlertWarning   string `json:"alert_warning"`
+	AlertCaution   string `json:"alert_caution"`
+	
 	// Search highlighting (for our search feature)
 	SearchCurrent  string `json:"search_current"`
 	SearchMatch    string `json:"search_match"`
@@ -106,8 +171,14 @@ func DefaultKeybindings() KeybindingConfig {
 		ScrollRight: []string{"l", "o", "Right"},
 		PageUp:      []string{"PageUp"},
 		PageDown:    []string{"PageDown", "Space"},
-		GoToTop:     []string{"g"},
+		GoToTop:     []string{"g g"},
 		GoToBottom:  []string{"G"},
+		FollowFootnote: []string{"f"},
+		FootnoteBack:   []string{"b"},
+		NextHeading:    []string{"]]"},
+		PrevHeading:    []string{"[["},
+		NextSiblingHeading: []string{"]["},
+		ParentHeading:  []string{"]u"},
 		
 		// Search
 		StartSearch: []string{"/", "C-f"},
@@ -119,63 +190,23 @@ func DefaultKeybindings() KeybindingConfig {
 		Quit:         []string{"q", "C-c"},
 		ShowHelp:     []string{"?"},
 		ToggleMouse:  []string{"m"},
+		CycleTheme:   []string{"t"},
+		ToggleDiagrams: []string{"d"},
+		ToggleFrontMatter: []string{"p"},
+		ToggleDetails:  []string{"z"},
+		CopyCodeBlock:  []string{"y"},
+		ToggleTOC:      []string{"c"},
 	}
 }
 
-// OneDarkConfig returns a config with One Dark theme
-func OneDarkConfig() *Config {
-	return &Config{
-		Keybindings: DefaultKeybindings(),
-		Colors: ColorConfig{
-			// Headings - One Dark blue/purple shades
-			Heading1:       "#61afef",
-			Heading2:       "#c678dd",
-			Heading3:       "#61afef",
-			Heading4:       "#c678dd",
-			Heading5:       "#56b6c2",
-			Heading6:       "#61afef",
-			
-			// Text elements
-			Bold:           "#abb2bf",
-			Italic:         "#98c379",
-			Strikethrough:  "#5c6370",
-			Link:           "#56b6c2",
-			LinkURL:        "#61afef",
-			
-			// Code
-			Code:           "#e5c07b",
-			CodeBlock:      "#e5c07b",
-			CodeBlockBg:    "#282c34",
-			
-			// Lists
-			ListMarker:     "#d19a66",
-			TaskChecked:    "#98c379",
-			TaskUnchecked:  "#e06c75",
-			
-			// Quotes and tables
-			BlockQuote:     "#5c6370",
-			TableHeader:    "#e5c07b",
-			TableRow:       "#abb2bf",
-			TableBorder:    "#5c6370",
-			
-			// Search
-			SearchCurrent:  "#d19a66", // Orange background for current match
-			SearchMatch:    "#e5c07b", // Yellow background for other matches
-			
-			// Status bar
-			StatusBarText:  "#5c6370", // Dark gray
-			StatusBarBg:    "",        // Transparent
-			
-			// UI elements
-			SearchBoxBorder: "#61afef", // Blue
-			HelpBoxBorder:   "#c678dd", // Purple
-			HoveredLinkURL:  "#56b6c2", // Cyan
-			
-			// Hyperlinks
-			HyperlinkText:             "", // Use default link color
-			HyperlinkUnderline:        "#56b6c2", // Cyan underline
-			HyperlinkHoveredUnderline: "#e5c07b", // Yellow underline when hovered
-		},
+// DefaultLayout returns the default layout configuration
+func DefaultLayout() LayoutConfig {
+	return LayoutConfig{
+		MaxWidth: 100,
+		Padding:  4,
+		Align:    "center",
+		Tables:   tablesWrap,
+		TOCWidth: 30,
 	}
 }
 
@@ -183,6 +214,11 @@ func OneDarkConfig() *Config {
 func DefaultConfig() *Config {
 	return &Config{
 		Keybindings: DefaultKeybindings(),
+		Layout:      DefaultLayout(),
+		SyntaxStyle: themeSyntaxStyle,
+		Emoji:       emojiUnicode,
+		Images:      imagesAuto,
+		KeyTimeout:  1000,
 		Colors: ColorConfig{
 			// Headings - blue shades
 			Heading1:       "#00d7ff",
@@ -215,6 +251,13 @@ func DefaultConfig() *Config {
 			TableRow:       "#ffffff",
 			TableBorder:    "#808080",
 			
+			// GitHub alerts
+			AlertNote:      "#4493f8",
+			AlertTip:       "#3fb950",
+			AlertImportant: "#ab7df8",
+			AlertWarning:   "#d29922",
+			AlertCaution:   "#f85149",
+			
 			// Search
 			SearchCurrent:  "#ff8700", // Orange background for current match
 			SearchMatch:    "#ffff00", // Yellow background for other matches
@@ -242,12 +285,9 @@ func LoadConfig() (*Config, error) {
 	
 	// Check if config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Create default config file
-		config := DefaultConfig()
-		if err := config.Save(); err != nil {
-			return config, nil // Return default config even if save fails
-		}
-		return config, nil
+		// Create a starter config file; the defaults apply even if that fails
+		saveStarterConfig()
+		return DefaultConfig(), nil
 	}
 	
 	// Read config file
@@ -256,20 +296,124 @@ func LoadConfig() (*Config, error) {
 		return DefaultConfig(), fmt.Errorf("failed to read config file: %w", err)
 	}
 	
-	// Parse JSON config
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return DefaultConfig(), fmt.Errorf("failed to parse config file: %w", err)
+	config, err := parseConfig(data)
+	if err != nil {
+		return DefaultConfig(), err
+	}
+	
+	return config, nil
+}
+
+// parseConfig parses a JSON config, layering it on top of the theme it names
+// or extends
+func parseConfig(data []byte) (*Config, error) {
+	configPath := getConfigPath()
+	config, err := parseThemeLayer(data, configPath, filepath.Dir(configPath), nil)
+	if err != nil {
+		return nil, err
 	}
 	
 	// Fill in any missing values with defaults
 	config.fillDefaults()
 	
-	return &config, nil
+	if err := config.validateSyntaxStyles(); err != nil {
+		return nil, err
+	}
+	
+	return config, nil
+}
+
+// configKeys returns the keys whose source is tracked: "syntax_style", then
+// the color, keybinding and layout keys as "<section>.<key>"
+func configKeys() []string {
+	sections := []struct {
+		prefix string
+		t      reflect.Type
+	}{
+		{"colors", reflect.TypeOf(ColorConfig{})},
+		{"keybindings", reflect.TypeOf(KeybindingConfig{})},
+		{"layout", reflect.TypeOf(LayoutConfig{})},
+	}
+	
+	keys := []string{"syntax_style"}
+	for _, section := range sections {
+		for i := 0; i < section.t.NumField(); i++ {
+			tag := strings.Split(section.t.Field(i).Tag.Get("json"), ",")[0]
+			keys = append(keys, section.prefix+"."+tag)
+		}
+	}
+	return keys
+}
+
+// setSources records source as the origin of every value
+func (c *Config) setSources(source string) {
+	c.sources = make(map[string]string)
+	for _, key := range configKeys() {
+		c.sources[key] = source
+	}
+}
+
+// recordSources records source as the origin of the values set in data
+func (c *Config) recordSources(data []byte, source string) {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return
+	}
+	
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	subsections := make(map[string]map[string]json.RawMessage)
+	for _, key := range configKeys() {
+		values, name := sections, key
+		if prefix, rest, ok := strings.Cut(key, "."); ok {
+			if _, parsed := subsections[prefix]; !parsed {
+				var sub map[string]json.RawMessage
+				json.Unmarshal(sections[prefix], &sub)
+				subsections[prefix] = sub
+			}
+			values, name = subsections[prefix], rest
+		}
+		if _, ok := values[name]; ok {
+			c.sources[key] = source
+		}
+	}
+}
+
+// ValueSource returns the file a key of configKeys was read from, or
+// "defaults" when no file set it
+func (c *Config) ValueSource(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "defaults"
 }
 
 // Save saves the configuration to file
 func (c *Config) Save() error {
+	return writeConfigFile(c)
+}
+
+// starterConfig is the config file written on first run. It names the
+// theme instead of holding its colors and syntax style, which would
+// otherwise override any theme named in the file later on.
+type starterConfig struct {
+	Theme       string           `json:"theme"`
+	Keybindings KeybindingConfig `json:"keybindings"`
+	Layout      LayoutConfig     `json:"layout"`
+}
+
+// saveStarterConfig writes the config file of a first run
+func saveStarterConfig() error {
+	return writeConfigFile(starterConfig{
+		Theme:       "default",
+		Keybindings: DefaultKeybindings(),
+		Layout:      DefaultLayout(),
+	})
+}
+
+// writeConfigFile writes config as JSON to the config file
+func writeConfigFile(config interface{}) error {
 	configPath := getConfigPath()
 	configDir := filepath.Dir(configPath)
 	
@@ -279,7 +423,7 @@ func (c *Config) Save() error {
 	}
 	
 	// Marshal config to JSON with indentation
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -305,12 +449,34 @@ func (c *Config) fillDefaults() {
 	if c.Keybindings.PageDown == nil { c.Keybindings.PageDown = defaults.Keybindings.PageDown }
 	if c.Keybindings.GoToTop == nil { c.Keybindings.GoToTop = defaults.Keybindings.GoToTop }
 	if c.Keybindings.GoToBottom == nil { c.Keybindings.GoToBottom = defaults.Keybindings.GoToBottom }
+	if c.Keybindings.FollowFootnote == nil { c.Keybindings.FollowFootnote = defaults.Keybindings.FollowFootnote }
+	if c.Keybindings.FootnoteBack == nil { c.Keybindings.FootnoteBack = defaults.Keybindings.FootnoteBack }
+	if c.Keybindings.NextHeading == nil { c.Keybindings.NextHeading = defaults.Keybindings.NextHeading }
+	if c.Keybindings.PrevHeading == nil { c.Keybindings.PrevHeading = defaults.Keybindings.PrevHeading }
+	if c.Keybindings.NextSiblingHeading == nil { c.Keybindings.NextSiblingHeading = defaults.Keybindings.NextSiblingHeading }
+	if c.Keybindings.ParentHeading == nil { c.Keybindings.ParentHeading = defaults.Keybindings.ParentHeading }
 	if c.Keybindings.StartSearch == nil { c.Keybindings.StartSearch = defaults.Keybindings.StartSearch }
 	if c.Keybindings.NextMatch == nil { c.Keybindings.NextMatch = defaults.Keybindings.NextMatch }
 	if c.Keybindings.PrevMatch == nil { c.Keybindings.PrevMatch = defaults.Keybindings.PrevMatch }
 	if c.Keybindings.ClearSearch == nil { c.Keybindings.ClearSearch = defaults.Keybindings.ClearSearch }
 	if c.Keybindings.Quit == nil { c.Keybindings.Quit = defaults.Keybindings.Quit }
 	if c.Keybindings.ShowHelp == nil { c.Keybindings.ShowHelp = defaults.Keybindings.ShowHelp }
+	if c.Keybindings.ToggleMouse == nil { c.Keybindings.ToggleMouse = defaults.Keybindings.ToggleMouse }
+	if c.Keybindings.CycleTheme == nil { c.Keybindings.CycleTheme = defaults.Keybindings.CycleTheme }
+	if c.Keybindings.ToggleDiagrams == nil { c.Keybindings.ToggleDiagrams = defaults.Keybindings.ToggleDiagrams }
+	if c.Keybindings.ToggleFrontMatter == nil { c.Keybindings.ToggleFrontMatter = defaults.Keybindings.ToggleFrontMatter }
+	if c.Keybindings.ToggleDetails == nil { c.Keybindings.ToggleDetails = defaults.Keybindings.ToggleDetails }
+	if c.Keybindings.CopyCodeBlock == nil { c.Keybindings.CopyCodeBlock = defaults.Keybindings.CopyCodeBlock }
+	if c.Keybindings.ToggleTOC == nil { c.Keybindings.ToggleTOC = defaults.Keybindings.ToggleTOC }
+	
+	if c.SyntaxStyle == "" { c.SyntaxStyle = defaults.SyntaxStyle }
+	if c.KeyTimeout <= 0 { c.KeyTimeout = defaults.KeyTimeout }
+	if c.Emoji != emojiUnicode && c.Emoji != emojiASCII && c.Emoji != emojiOff { c.Emoji = defaults.Emoji }
+	switch c.Images {
+	case imagesAuto, imagesProtocol, imagesBlocks, imagesAltText, imagesOff:
+	default:
+		c.Images = defaults.Images
+	}
 	
 	if c.Colors.Heading1 == "" { c.Colors.Heading1 = defaults.Colors.Heading1 }
 	if c.Colors.Heading2 == "" { c.Colors.Heading2 = defaults.Colors.Heading2 }
@@ -338,6 +504,12 @@ func (c *Config) fillDefaults() {
 	if c.Colors.TableRow == "" { c.Colors.TableRow = defaults.Colors.TableRow }
 	if c.Colors.TableBorder == "" { c.Colors.TableBorder = defaults.Colors.TableBorder }
 	
+	if c.Colors.AlertNote == "" { c.Colors.AlertNote = defaults.Colors.AlertNote }
+	if c.Colors.AlertTip == "" { c.Colors.AlertTip = defaults.Colors.AlertTip }
+	if c.Colors.AlertImportant == "" { c.Colors.AlertImportant = defaults.Colors.AlertImportant }
+	if c.Colors.AlertWarning == "" { c.Colors.AlertWarning = defaults.Colors.AlertWarning }
+	if c.Colors.AlertCaution == "" { c.Colors.AlertCaution = defaults.Colors.AlertCaution }
+	
 	if c.Colors.SearchCurrent == "" { c.Colors.SearchCurrent = defaults.Colors.SearchCurrent }
 	if c.Colors.SearchMatch == "" { c.Colors.SearchMatch = defaults.Colors.SearchMatch }
 	
@@ -356,6 +528,13 @@ func (c *Config) fillDefaults() {
 	
 	// Note: StatusBarText, StatusBarBg, and HyperlinkText can be empty (for defaults/transparent)
 	// So we don't fill defaults for them if empty - empty is a valid value
+	
+	// Layout values start from DefaultLayout, so only invalid ones are replaced
+	if c.Layout.MaxWidth < 0 { c.Layout.MaxWidth = 0 }
+	if c.Layout.Padding < 0 { c.Layout.Padding = defaults.Layout.Padding }
+	if c.Layout.Align != "left" && c.Layout.Align != "center" { c.Layout.Align = defaults.Layout.Align }
+	if c.Layout.Tables != tablesWrap && c.Layout.Tables != tablesScroll { c.Layout.Tables = defaults.Layout.Tables }
+	if c.Layout.TOCWidth < minTOCWidth { c.Layout.TOCWidth = defaults.Layout.TOCWidth }
 }
 
 // getConfigPath returns the path to the config file
@@ -368,77 +547,56 @@ func getConfigPath() string {
 	return filepath.Join(homeDir, ".config", "bleamd", "config.json")
 }
 
-// hexToANSI converts a hex color to the nearest ANSI 256 color
-func hexToANSI(hex string) (int, error) {
-	// Remove # prefix if present
-	hex = strings.TrimPrefix(hex, "#")
-	
-	// Parse hex color
-	if len(hex) != 6 {
-		return 0, fmt.Errorf("invalid hex color: %s", hex)
-	}
-	
-	r, err := strconv.ParseInt(hex[0:2], 16, 64)
-	if err != nil {
-		return 0, err
-	}
-	g, err := strconv.ParseInt(hex[2:4], 16, 64)
-	if err != nil {
-		return 0, err
-	}
-	b, err := strconv.ParseInt(hex[4:6], 16, 64)
-	if err != nil {
-		return 0, err
-	}
-	
-	// Convert to ANSI 256 color
-	// This is a simplified conversion - more sophisticated algorithms exist
-	if r == g && g == b {
-		// Grayscale
-		if r < 8 {
-			return 16, nil
-		}
-		if r > 248 {
-			return 231, nil
-		}
-		return int(232 + ((r-8)/10)), nil
-	}
-	
-	// Color cube (6x6x6)
-	r = (r * 5) / 255
-	g = (g * 5) / 255
-	b = (b * 5) / 255
-	
-	return int(16 + (36 * r) + (6 * g) + b), nil
+// getUserThemesDir returns the directory holding the user's own themes
+func getUserThemesDir() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), "themes")
 }
 
-// GetANSIColor returns ANSI escape code for a hex color
+// GetANSIColor returns ANSI escape code for a hex color in the active color profile
 func (c *ColorConfig) GetANSIColor(hex string) string {
 	if hex == "" {
 		return ""
 	}
 	
-	colorCode, err := hexToANSI(hex)
+	seq, err := colorSequence(hex, 38)
 	if err != nil {
 		// Return default color on error
 		return "\033[0m"
 	}
 	
-	return fmt.Sprintf("\033[38;5;%dm", colorCode)
+	return seq
 }
 
-// GetANSIBackground returns ANSI escape code for background color
+// GetANSIBackground returns ANSI escape code for background color in the active color profile
 func (c *ColorConfig) GetANSIBackground(hex string) string {
 	if hex == "" {
 		return ""
 	}
 	
-	colorCode, err := hexToANSI(hex)
+	seq, err := colorSequence(hex, 48)
 	if err != nil {
 		return ""
 	}
 	
-	return fmt.Sprintf("\033[48;5;%dm", colorCode)
+	return seq
+}
+
+// GetLipglossColor converts a hex color for use in lipgloss styles
+func (c *ColorConfig) GetLipglossColor(hex string) (lipgloss.TerminalColor, bool) {
+	rgb, err := parseHexColor(hex)
+	if err != nil {
+		return nil, false
+	}
+	
+	switch colorProfile {
+	case ProfileTrueColor:
+		return lipgloss.Color(hex), true
+	case ProfileANSI256:
+		return lipgloss.Color(fmt.Sprintf("%d", nearestANSI256(rgb))), true
+	case ProfileANSI16:
+		return lipgloss.Color(fmt.Sprintf("%d", nearestANSI16(rgb))), true
+	}
+	return lipgloss.NoColor{}, true
 }
 
 // GetMarkdownOptions returns markdown rendering options based on config
@@ -447,15 +605,19 @@ func (c *Config) GetMarkdownOptions() []markdown.Options {
 		markdown.WithImageDithering(markdown.DitheringWithBlocks),
 	}
 	
-	// Note: The go-term-markdown library may not support all color customizations
-	// We'll need to work with what's available in the API
-	// This is a placeholder for when we integrate with the actual markdown renderer
-	
 	return opts
 }
 
 // ApplySearchHighlight applies search highlighting colors to text
 func (c *Config) ApplySearchHighlight(text string, isCurrent bool) string {
+	if colorProfile == ProfileNoColor {
+		// Without colors, fall back to reverse video (bold for the current match)
+		if isCurrent {
+			return fmt.Sprintf("\033[1;7m%s\033[0m", text)
+		}
+		return fmt.Sprintf("\033[7m%s\033[0m", text)
+	}
+	
 	if isCurrent {
 		// Current match - orange background
 		bgColor := c.Colors.GetANSIBackground(c.Colors.SearchCurrent)
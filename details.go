@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// <details> blocks are collapsed to their summary, which becomes a link to
+// "#details-<n>" with a ▶ marker, n counting the blocks in document order.
+// Following the link toggles the block, which is then rendered with its
+// content and a ▼ marker. Blocks with the open attribute start expanded.
+
+const detailsURL = "#details-"
+
+var (
+	detailsOpenPattern  = regexp.MustCompile(`(?i)^\s*<details(\s[^>]*)?>\s*(.*)$`)
+	detailsClosePattern = regexp.MustCompile(`(?i)^(.*?)</details\s*>\s*$`)
+	detailsAttrPattern  = regexp.MustCompile(`(?i)(^|\s)open(\s|=|$)`)
+	summaryPattern      = regexp.MustCompile(`(?i)^\s*<summary(?:\s[^>]*)?>(.*?)</summary\s*>\s*(.*)$`)
+	linkTextEscaper     = strings.NewReplacer("[", `\[`, "]", `\]`)
+)
+
+// processDetails replaces the <details> blocks of markdown with their
+// summary, followed by their content when expanded. toggled holds the blocks
+// whose state differs from the one the document sets.
+func processDetails(markdown string, toggled map[int]bool) string {
+	lines := strings.Split(markdown, "\n")
+	var out []string
+	var expanded []bool // the state of the blocks around the current line
+	hidden := 0         // the number of those that are collapsed
+	count := 0
+	var fences codeFences
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fences.inCode(line) {
+			if hidden == 0 {
+				out = append(out, line)
+			}
+			continue
+		}
+
+		if match := detailsOpenPattern.FindStringSubmatch(line); match != nil {
+			n := count
+			count++
+			open := detailsAttrPattern.MatchString(match[1]) != toggled[n]
+
+			// The summary follows the tag, on the same line or the next one
+			summary, rest := "", match[2]
+			if rest == "" && i+1 < len(lines) && summaryPattern.MatchString(lines[i+1]) {
+				i++
+				rest = lines[i]
+			}
+			if match := summaryPattern.FindStringSubmatch(rest); match != nil {
+				// Link text can't hold emphasis, so tags are dropped
+				summary, rest = strings.TrimSpace(htmlTagPattern.ReplaceAllString(match[1], "")), match[2]
+			}
+			if summary == "" {
+				summary = "Details"
+			}
+
+			if hidden == 0 {
+				marker := "▶"
+				if open {
+					marker = "▼"
+				}
+				out = append(out, "", fmt.Sprintf("[%s %s](%s%d)", marker, linkTextEscaper.Replace(summary), detailsURL, n), "")
+			}
+			expanded = append(expanded, open)
+			if !open {
+				hidden++
+			}
+
+			// Whatever follows the summary on its line is read again
+			if strings.TrimSpace(rest) != "" {
+				lines[i] = rest
+				i--
+			}
+			continue
+		}
+
+		if match := detailsClosePattern.FindStringSubmatch(line); match != nil && len(expanded) > 0 {
+			if hidden == 0 && strings.TrimSpace(match[1]) != "" {
+				out = append(out, match[1])
+			}
+			if !expanded[len(expanded)-1] {
+				hidden--
+			}
+			expanded = expanded[:len(expanded)-1]
+			if hidden == 0 {
+				out = append(out, "")
+			}
+			continue
+		}
+
+		if hidden == 0 {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// isDetailsURL tells whether url is the link of a <details> summary
+func isDetailsURL(url string) bool {
+	return strings.HasPrefix(url, detailsURL)
+}
+
+// detailsIndex returns the block a <details> summary link points to
+func detailsIndex(url string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimPrefix(url, detailsURL))
+	return n, err == nil && isDetailsURL(url)
+}
+
+// toggleDetails expands or collapses the <details> block of a summary link,
+// keeping the summary at the same place on screen
+func (m model) toggleDetails(url string) model {
+	n, ok := detailsIndex(url)
+	if !ok {
+		return m
+	}
+	if m.detailsToggled == nil {
+		m.detailsToggled = make(map[int]bool)
+	}
+	m.detailsToggled[n] = !m.detailsToggled[n]
+
+	m = m.refresh()
+	m.yOffset = max(0, min(m.yOffset, m.lines-m.height+1))
+	return m.updateLinkPositions()
+}
+
+// toggleVisibleDetails toggles the first <details> block whose summary is on
+// screen, or else the closest one above the screen
+func (m model) toggleVisibleDetails() model {
+	for _, link := range m.linkPositions {
+		if isDetailsURL(link.url) {
+			return m.toggleDetails(link.url)
+		}
+	}
+
+	target := "\x1b]8;;" + detailsURL
+	lines := strings.Split(string(m.renderedContent), "\n")
+	for i := min(m.yOffset, len(lines)) - 1; i >= 0; i-- {
+		start := strings.Index(lines[i], target)
+		if start < 0 {
+			continue
+		}
+		url := lines[i][start+len("\x1b]8;;"):]
+		url = url[:strings.Index(url+"\x1b", "\x1b")]
+		m = m.scrollToLine(i)
+		return m.toggleDetails(url)
+	}
+	return m
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestProcessDetails(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		toggled  map[int]bool
+		expected string
+	}{
+		{
+			name:     "collapsed",
+			input:    "<details>\n<summary>More [info]</summary>\n\nHidden.\n</details>\nAfter",
+			expected: "\n[▶ More \\[info\\]](#details-0)\n\n\nAfter",
+		},
+		{
+			name:     "expanded",
+			input:    "<details>\n<summary>More</summary>\n\nShown.\n</details>\nAfter",
+			toggled:  map[int]bool{0: true},
+			expected: "\n[▼ More](#details-0)\n\n\nShown.\n\nAfter",
+		},
+		{
+			name:     "open attribute and nesting",
+			input:    "<details open><summary><b>A</b></summary>\nText\n<details><summary>B</summary>\nInner\n</details>\n</details>\n<details>\nNo summary\n</details>",
+			expected: "\n[▼ A](#details-0)\n\nText\n\n[▶ B](#details-1)\n\n\n\n\n[▶ Details](#details-2)\n\n",
+		},
+		{
+			name:     "collapsed parent",
+			input:    "<details><summary>A</summary>\n<details open><summary>B</summary>\nInner\n</details>\n</details>\n<details><summary>C</summary>\n</details>",
+			expected: "\n[▶ A](#details-0)\n\n\n\n[▶ C](#details-2)\n\n",
+		},
+		{
+			name:     "code block",
+			input:    "```html\n<details>\n```",
+			expected: "```html\n<details>\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processDetails(tt.input, tt.toggled); got != tt.expected {
+				t.Errorf("processDetails() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToggleDetails(t *testing.T) {
+	useColorProfile(t, ProfileANSI256)
+
+	raw := "Intro.\n\n<details>\n<summary>FAQ</summary>\n\n" + strings.Repeat("Answer paragraph.\n\n", 10) + "</details>\n\nThe end.\n"
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 80
+	m.height = 10
+	m.search.SetTerm("paragraph", string(m.renderedContent))
+	m = m.updateLinkPositions()
+
+	collapsed := m.lines
+	if strings.Contains(stripANSI(string(m.renderedContent)), "Answer") || m.search.GetMatchCount() != 0 {
+		t.Fatalf("Expected the details to start collapsed, got:\n%s", stripANSI(string(m.renderedContent)))
+	}
+
+	// Expand from the keyboard
+	updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	m = updated.(model)
+	rendered := stripANSI(string(m.renderedContent))
+	if !strings.Contains(rendered, "▼ FAQ") || !strings.Contains(rendered, "Answer paragraph.") {
+		t.Fatalf("Expected the details to be expanded, got:\n%s", rendered)
+	}
+	if m.lines != strings.Count(string(m.renderedContent), "\n") || m.lines <= collapsed {
+		t.Errorf("Expected the line count to follow the content, got %d", m.lines)
+	}
+	if m.search.GetMatchCount() != 10 {
+		t.Errorf("Expected 10 search matches in the expanded details, got %d", m.search.GetMatchCount())
+	}
+
+	// Collapse again by clicking the summary
+	var summary linkPosition
+	for _, link := range m.linkPositions {
+		if isDetailsURL(link.url) {
+			summary = link
+		}
+	}
+	if summary.url == "" {
+		t.Fatal("Expected the summary to be on screen")
+	}
+	updated, _ = m.handleMouseMsg(tea.MouseMsg{X: summary.x, Y: summary.y, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+	m = updated.(model)
+	if m.lines != collapsed || m.search.GetMatchCount() != 0 {
+		t.Errorf("Expected the details to be collapsed, got %d lines and %d matches", m.lines, m.search.GetMatchCount())
+	}
+	if !strings.Contains(stripANSI(string(m.renderedContent)), "▶ FAQ") {
+		t.Errorf("Expected the collapsed marker, got:\n%s", stripANSI(string(m.renderedContent)))
+	}
+}
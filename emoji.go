@@ -0,0 +1,165 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+)
+
+// Emoji shortcodes (:rocket:) are expanded before rendering, outside code
+// spans and code blocks. go-term-markdown would expand the ones it knows as
+// well, followed by a padding space, so shortcodes that are left alone are
+// escaped to keep it from doing so.
+
+// Values of the emoji setting
+const (
+	emojiUnicode = "unicode" // expand shortcodes to emoji
+	emojiASCII   = "ascii"   // expand shortcodes to their ASCII fallback
+	emojiOff     = "off"     // show shortcodes as written
+)
+
+var (
+	emojiShortcodePattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+	emojiFlagPattern      = regexp.MustCompile(`^:flag-([a-z]{2}):$`)
+)
+
+// emojiFallbacks holds the ASCII fallback of the emoji that have a natural
+// one. The others keep their shortcode.
+var emojiFallbacks = map[string]string{
+	// Faces and gestures
+	"smile":                 ":)",
+	"smiley":                ":)",
+	"slightly_smiling_face": ":)",
+	"blush":                 ":)",
+	"grinning":              ":D",
+	"grin":                  ":D",
+	"laughing":              "XD",
+	"joy":                   "XD",
+	"wink":                  ";)",
+	"neutral_face":          ":|",
+	"confused":              ":/",
+	"disappointed":          ":(",
+	"frowning":              ":(",
+	"cry":                   ":'(",
+	"sob":                   ":'(",
+	"open_mouth":            ":O",
+	"astonished":            ":O",
+	"stuck_out_tongue":      ":P",
+	"sunglasses":            "B)",
+	"angry":                 ">:(",
+	"eyes":                  "o_o",
+	"heart":                 "<3",
+	"broken_heart":          "</3",
+	"+1":                    "(+1)",
+	"thumbsup":              "(+1)",
+	"-1":                    "(-1)",
+	"thumbsdown":            "(-1)",
+	"wave":                  "o/",
+	"point_right":           "=>",
+	"point_left":            "<=",
+	"point_up":              "^",
+	"point_down":            "v",
+
+	// Status
+	"white_check_mark":            "[OK]",
+	"heavy_check_mark":            "[OK]",
+	"ballot_box_with_check":       "[OK]",
+	"x":                           "[X]",
+	"negative_squared_cross_mark": "[X]",
+	"heavy_multiplication_x":      "x",
+	"warning":                     "[!]",
+	"rotating_light":              "[!!]",
+	"exclamation":                 "!",
+	"heavy_exclamation_mark":      "!",
+	"grey_exclamation":            "!",
+	"question":                    "?",
+	"grey_question":               "?",
+	"information_source":          "[i]",
+	"no_entry":                    "[-]",
+	"no_entry_sign":               "[-]",
+	"construction":                "[WIP]",
+	"ok":                          "[OK]",
+	"new":                         "[NEW]",
+	"free":                        "[FREE]",
+	"up":                          "[UP]",
+	"cool":                        "[COOL]",
+	"sos":                         "[SOS]",
+	"100":                         "100",
+
+	// Symbols
+	"arrow_right":         "->",
+	"arrow_left":          "<-",
+	"arrow_up":            "^",
+	"arrow_down":          "v",
+	"left_right_arrow":    "<->",
+	"arrow_forward":       ">",
+	"arrow_backward":      "<",
+	"heavy_plus_sign":     "+",
+	"heavy_minus_sign":    "-",
+	"heavy_division_sign": "/",
+	"heavy_dollar_sign":   "$",
+	"star":                "*",
+	"star2":               "*",
+	"sparkles":            "*",
+	"copyright":           "(c)",
+	"registered":          "(R)",
+	"tm":                  "(TM)",
+	"radio_button":        "(o)",
+	"hash":                "#",
+	"asterisk":            "*",
+	"zero":                "0",
+	"one":                 "1",
+	"two":                 "2",
+	"three":               "3",
+	"four":                "4",
+	"five":                "5",
+	"six":                 "6",
+	"seven":               "7",
+	"eight":               "8",
+	"nine":                "9",
+	"keycap_ten":          "10",
+}
+
+// emojiFor returns the emoji of a :shortcode:, or an empty string.
+// :flag-xx: stands for the flag of the country with the code xx.
+func emojiFor(code string) string {
+	if match := emojiFlagPattern.FindStringSubmatch(code); match != nil {
+		return string('\U0001F1E6'+rune(match[1][0]-'a')) + string('\U0001F1E6'+rune(match[1][1]-'a'))
+	}
+	return emoji.CodeMap()[code]
+}
+
+// processEmoji expands the emoji shortcodes of markdown as set by mode
+func processEmoji(markdown, mode string) string {
+	lines := strings.Split(markdown, "\n")
+	var fences codeFences
+	for i, line := range lines {
+		if fences.inCode(line) {
+			continue
+		}
+		lines[i] = outsideCodeSpans(line, func(s string) string {
+			return emojiShortcodePattern.ReplaceAllStringFunc(s, func(code string) string {
+				return shortcode(code, mode)
+			})
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shortcode returns the replacement of a :shortcode:. Codes without one are
+// kept, escaped if go-term-markdown knows them.
+func shortcode(code, mode string) string {
+	known := emojiFor(code)
+	switch {
+	case known == "":
+		return code
+	case mode == emojiUnicode:
+		return known
+	case mode == emojiASCII:
+		if fallback, ok := emojiFallbacks[strings.Trim(code, ":")]; ok {
+			return markdownEscape(fallback)
+		}
+	}
+	return `\` + code
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcessEmoji(t *testing.T) {
+	tests := []struct {
+		mode     string
+		markdown string
+		expected string
+	}{
+		{emojiUnicode, "Ship it :rocket: :white_check_mark:", "Ship it 🚀 ✅"},
+		{emojiUnicode, "At 10:30:45 :not_an_emoji: :flag-fr:", "At 10:30:45 :not_an_emoji: 🇫🇷"},
+		{emojiUnicode, "Code `:rocket:` and ``a `:x:` b`` :x:", "Code `:rocket:` and ``a `:x:` b`` ❌"},
+		{emojiUnicode, "```\n:rocket:\n```\n:tada:", "```\n:rocket:\n```\n🎉"},
+		{emojiASCII, ":warning: :+1: :heavy_minus_sign: :smile:", "\\[\\!\\] \\(\\+1\\) \\- \\:\\)"},
+		{emojiASCII, "Launch :rocket:", "Launch \\:rocket:"},
+		{emojiOff, ":warning: :unknown:", "\\:warning: :unknown:"},
+	}
+
+	for _, tt := range tests {
+		if got := processEmoji(tt.markdown, tt.mode); got != tt.expected {
+			t.Errorf("processEmoji(%q, %q) = %q, expected %q", tt.markdown, tt.mode, got, tt.expected)
+		}
+	}
+}
+
+func TestRenderEmoji(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{emojiUnicode, "Done ✅ and 🚀 now"},
+		{emojiASCII, "Done [OK] and :rocket: now"},
+		{emojiOff, "Done :white_check_mark: and :rocket: now"},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Emoji = tt.mode
+		m := model{raw: "Done :white_check_mark: and :rocket: now\n", width: 80, config: config}
+		if got := stripANSI(string(m.render())); !strings.Contains(got, tt.expected) {
+			t.Errorf("render() with emoji %q = %q, expected %q", tt.mode, got, tt.expected)
+		}
+	}
+}
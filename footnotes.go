@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Footnotes are rewritten into plain links before rendering. A reference
+// becomes a superscript number linking to "#fn-<n>", and the definitions are
+// collected in a numbered list at the end of the document, each starting with
+// a back link to "#fnref-<n>". The viewer finds the target of a footnote link
+// by looking for the line holding the opposite link.
+
+const (
+	footnoteURL    = "#fn-"
+	footnoteRefURL = "#fnref-"
+)
+
+var (
+	footnoteDefPattern  = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:\s*(.*)$`)
+	footnoteRefPattern  = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	footnoteContPattern = regexp.MustCompile(`^(?: {4}|\t)\s*(\S.*)$`)
+)
+
+// processFootnotes turns footnote references into links and moves the
+// definitions to the end of the document. Footnotes are numbered in the order
+// they are first referenced; unreferenced definitions are dropped.
+func processFootnotes(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+
+	// Collect the definitions, including their indented continuation lines
+	definitions := make(map[string]string)
+	var body []string
+	var fences codeFences
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		match := footnoteDefPattern.FindStringSubmatch(line)
+		if fences.inCode(line) || match == nil {
+			body = append(body, line)
+			continue
+		}
+
+		text := []string{match[2]}
+		for i+1 < len(lines) {
+			cont := footnoteContPattern.FindStringSubmatch(lines[i+1])
+			if cont == nil {
+				break
+			}
+			text = append(text, cont[1])
+			i++
+		}
+		if _, ok := definitions[match[1]]; !ok {
+			definitions[match[1]] = strings.Join(text, " ")
+		}
+	}
+	if len(definitions) == 0 {
+		return markdown
+	}
+
+	// Replace the references, numbering them on first use
+	numbers := make(map[string]int)
+	var order []string
+	fences = codeFences{}
+	for i, line := range body {
+		if fences.inCode(line) {
+			continue
+		}
+		body[i] = replaceOutsideCode(line, footnoteRefPattern, func(ref string) string {
+			label := footnoteRefPattern.FindStringSubmatch(ref)[1]
+			if _, ok := definitions[label]; !ok {
+				return ref
+			}
+			if _, ok := numbers[label]; !ok {
+				order = append(order, label)
+				numbers[label] = len(order)
+			}
+			n := numbers[label]
+			return fmt.Sprintf("[%s](%s%d)", superscript(n), footnoteURL, n)
+		})
+	}
+	if len(order) == 0 {
+		return strings.Join(body, "\n")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(strings.Join(body, "\n"), "\n"))
+	sb.WriteString("\n\n---\n\n")
+	for i, label := range order {
+		fmt.Fprintf(&sb, "%d. [↩](%s%d) %s\n", i+1, footnoteRefURL, i+1, definitions[label])
+	}
+	return sb.String()
+}
+
+// replaceOutsideCode replaces the matches of pattern in line that are not
+// inside inline code spans
+func replaceOutsideCode(line string, pattern *regexp.Regexp, repl func(string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(line, -1) {
+		sb.WriteString(line[last:loc[0]])
+		if strings.Count(line[:loc[0]], "`")%2 == 1 {
+			sb.WriteString(line[loc[0]:loc[1]])
+		} else {
+			sb.WriteString(repl(line[loc[0]:loc[1]]))
+		}
+		last = loc[1]
+	}
+	sb.WriteString(line[last:])
+	return sb.String()
+}
+
+// superscript writes n with superscript digits
+func superscript(n int) string {
+	digits := []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+	var sb strings.Builder
+	for _, d := range strconv.Itoa(n) {
+		sb.WriteRune(digits[d-'0'])
+	}
+	return sb.String()
+}
+
+// isFootnoteURL tells whether url is a footnote reference or back link
+func isFootnoteURL(url string) bool {
+	return strings.HasPrefix(url, footnoteURL) || strings.HasPrefix(url, footnoteRefURL)
+}
+
+// footnoteTargetLine returns the line of rendered that a footnote link
+// points to, or -1. A reference points to the line of its definition, which
+// holds the back link, and the other way round.
+func footnoteTargetLine(rendered []byte, url string) int {
+	var opposite string
+	switch {
+	case strings.HasPrefix(url, footnoteURL):
+		opposite = footnoteRefURL + strings.TrimPrefix(url, footnoteURL)
+	case strings.HasPrefix(url, footnoteRefURL):
+		opposite = footnoteURL + strings.TrimPrefix(url, footnoteRefURL)
+	default:
+		return -1
+	}
+
+	target := "\x1b]8;;" + opposite + "\x1b\\"
+	for i, line := range strings.Split(string(rendered), "\n") {
+		if strings.Contains(line, target) {
+			return i
+		}
+	}
+	return -1
+}
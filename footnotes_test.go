@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestProcessFootnotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "numbered by first reference",
+			input:    "A[^b] and B[^a], A again[^b].\n\n[^a]: First.\n[^b]: Second.\n",
+			expected: "A[¹](#fn-1) and B[²](#fn-2), A again[¹](#fn-1).\n\n---\n\n1. [↩](#fnref-1) Second.\n2. [↩](#fnref-2) First.\n",
+		},
+		{
+			name:     "continuation lines",
+			input:    "Text[^note]\n\n[^note]: Spans\n    two lines.\n",
+			expected: "Text[¹](#fn-1)\n\n---\n\n1. [↩](#fnref-1) Spans two lines.\n",
+		},
+		{
+			name:     "undefined and unreferenced",
+			input:    "Text[^missing]\n\n[^unused]: Never referenced.\n",
+			expected: "Text[^missing]\n\n",
+		},
+		{
+			name:     "code is left alone",
+			input:    "Text[^1] and `[^1]`\n\n```\n[^1]\n```\n\n[^1]: Note.\n",
+			expected: "Text[¹](#fn-1) and `[^1]`\n\n```\n[^1]\n```\n\n---\n\n1. [↩](#fnref-1) Note.\n",
+		},
+		{
+			name:     "no footnotes",
+			input:    "Just [a link](https://example.com).\n",
+			expected: "Just [a link](https://example.com).\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processFootnotes(tt.input); got != tt.expected {
+				t.Errorf("processFootnotes() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFootnoteNavigation(t *testing.T) {
+	useColorProfile(t, ProfileANSI256)
+
+	raw := "Some text with a footnote[^1].\n" + strings.Repeat("\nFiller paragraph.\n", 30) + "\n[^1]: The footnote.\n"
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 80
+	m.height = 10
+	m = m.updateLinkPositions()
+
+	// Follow the reference from the keyboard
+	updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	m = updated.(model)
+	lines := strings.Split(stripANSI(string(m.renderedContent)), "\n")
+	definition := -1
+	for i, line := range lines {
+		if strings.Contains(line, "The footnote.") {
+			definition = i
+		}
+	}
+	onScreen := func(m model) bool {
+		return definition >= m.yOffset && definition < m.yOffset+m.height-2
+	}
+	if !onScreen(m) {
+		t.Fatalf("Expected to scroll to the definition on line %d, got offset %d", definition, m.yOffset)
+	}
+
+	// Click the back link
+	var back linkPosition
+	for _, link := range m.linkPositions {
+		if link.url == footnoteRefURL+"1" {
+			back = link
+		}
+	}
+	if back.url == "" {
+		t.Fatal("Expected the back link to be on screen")
+	}
+	updated, _ = m.handleMouseMsg(tea.MouseMsg{X: back.x, Y: back.y, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+	m = updated.(model)
+	if m.yOffset != 0 {
+		t.Errorf("Expected to scroll back to the reference, got %d", m.yOffset)
+	}
+
+	// Return to the definition, then to where the first jump started
+	m = m.footnoteBack()
+	if !onScreen(m) {
+		t.Errorf("Expected to return to the definition, got offset %d", m.yOffset)
+	}
+	m = m.footnoteBack()
+	if m.yOffset != 0 || len(m.footnoteReturns) != 0 {
+		t.Errorf("Expected to return to the top, got %d", m.yOffset)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	text "github.com/MichaelMure/go-term-text"
+)
+
+// Front matter is the YAML (---) or TOML (+++) metadata block some documents
+// start with. It is split from the body before rendering and shown as a
+// header above the document, either the common fields or all of them. Only
+// the parts of both languages used for metadata are understood: scalars,
+// lists and nested tables, whose keys are joined with dots.
+
+// frontMatter holds the fields of a front matter block in document order
+type frontMatter struct {
+	fields []metaField
+}
+
+type metaField struct {
+	key    string
+	values []string
+}
+
+var (
+	yamlKeyPattern  = regexp.MustCompile(`^(\s*)([\w.-]+|"[^"]*"|'[^']*')\s*:(?:\s+(.*))?$`)
+	yamlItemPattern = regexp.MustCompile(`^(\s*)-\s+(.*)$`)
+	tomlKeyPattern  = regexp.MustCompile(`^\s*([\w.-]+|"[^"]*")\s*=\s*(.*)$`)
+	tomlPattern     = regexp.MustCompile(`^\s*\[+\s*([^\]]+?)\s*\]+\s*$`)
+)
+
+// splitFrontMatter returns the front matter of markdown and the body after
+// it. Documents without front matter are returned whole.
+func splitFrontMatter(markdown string) (frontMatter, string) {
+	lines := strings.Split(markdown, "\n")
+	if len(lines) < 2 {
+		return frontMatter{}, markdown
+	}
+
+	opening := strings.TrimRight(lines[0], " \r")
+	var closings []string
+	var parse func([]string) frontMatter
+	switch opening {
+	case "---":
+		closings = []string{"---", "..."}
+		parse = parseYAMLFrontMatter
+	case "+++":
+		closings = []string{"+++"}
+		parse = parseTOMLFrontMatter
+	default:
+		return frontMatter{}, markdown
+	}
+
+	for end := 1; end < len(lines); end++ {
+		line := strings.TrimRight(lines[end], " \r")
+		if line != closings[0] && (len(closings) < 2 || line != closings[1]) {
+			continue
+		}
+		meta := parse(lines[1:end])
+		if len(meta.fields) == 0 {
+			// A horizontal rule rather than front matter
+			return frontMatter{}, markdown
+		}
+		return meta, strings.Join(lines[end+1:], "\n")
+	}
+	return frontMatter{}, markdown
+}
+
+// add appends a field, or a value to the field of the same key
+func (f *frontMatter) add(key string, values ...string) {
+	for i := range f.fields {
+		if f.fields[i].key == key {
+			f.fields[i].values = append(f.fields[i].values, values...)
+			return
+		}
+	}
+	f.fields = append(f.fields, metaField{key: key, values: values})
+}
+
+// get returns the values of the first of keys present, ignoring case
+func (f frontMatter) get(keys ...string) []string {
+	for _, key := range keys {
+		for _, field := range f.fields {
+			if strings.EqualFold(field.key, key) && len(field.values) > 0 {
+				return field.values
+			}
+		}
+	}
+	return nil
+}
+
+// title returns the title of the document, or an empty string
+func (f frontMatter) title() string {
+	return strings.Join(f.get("title"), " ")
+}
+
+// parseYAMLFrontMatter parses the lines of a YAML front matter block. Nested
+// mappings are told apart by their indentation.
+func parseYAMLFrontMatter(lines []string) frontMatter {
+	var meta frontMatter
+	type parent struct {
+		indent int
+		key    string
+	}
+	var parents []parent
+	key := ""
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		if match := yamlItemPattern.FindStringSubmatch(line); match != nil && key != "" {
+			meta.add(key, yamlScalar(match[2]))
+			continue
+		}
+		match := yamlKeyPattern.FindStringSubmatch(line)
+		if match == nil {
+			// Not a mapping, so not front matter
+			return frontMatter{}
+		}
+
+		indent := len(match[1])
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		key = strings.Trim(match[2], `"'`)
+		if len(parents) > 0 {
+			key = parents[len(parents)-1].key + "." + key
+		}
+
+		value := strings.TrimSpace(match[3])
+		switch {
+		case value == "":
+			parents = append(parents, parent{indent, key})
+		case value == "|" || value == ">" || value == "|-" || value == ">-":
+			// Block scalars hold the lines indented below the key
+			var block []string
+			for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || len(lines[i+1])-len(strings.TrimLeft(lines[i+1], " ")) > indent) {
+				i++
+				if part := strings.TrimSpace(lines[i]); part != "" {
+					block = append(block, part)
+				}
+			}
+			meta.add(key, strings.Join(block, " "))
+		case strings.HasPrefix(value, "["):
+			meta.add(key, flowList(value)...)
+		default:
+			meta.add(key, yamlScalar(value))
+		}
+	}
+	return meta
+}
+
+// yamlScalar returns the text of a YAML scalar, without quotes and comments
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.LastIndexByte(value, value[0]); end > 0 {
+			return value[1:end]
+		}
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+	return value
+}
+
+// flowList returns the items of a [a, "b", c] list
+func flowList(value string) []string {
+	value = strings.TrimSpace(value)
+	if end := strings.LastIndexByte(value, ']'); end > 0 {
+		value = value[1:end]
+	} else {
+		value = strings.TrimPrefix(value, "[")
+	}
+
+	var items []string
+	quote := byte(0)
+	start := 0
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) {
+			switch c := value[i]; {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c != ',':
+				continue
+			}
+		}
+		if item := yamlScalar(value[start:i]); item != "" {
+			items = append(items, item)
+		}
+		start = i + 1
+	}
+	return items
+}
+
+// parseTOMLFrontMatter parses the lines of a TOML front matter block
+func parseTOMLFrontMatter(lines []string) frontMatter {
+	var meta frontMatter
+	table := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if match := tomlPattern.FindStringSubmatch(line); match != nil {
+			table = strings.Trim(match[1], `"`) + "."
+			continue
+		}
+		match := tomlKeyPattern.FindStringSubmatch(line)
+		if match == nil {
+			return frontMatter{}
+		}
+
+		key := table + strings.Trim(match[1], `"`)
+		if value := strings.TrimSpace(match[2]); strings.HasPrefix(value, "[") {
+			meta.add(key, flowList(value)...)
+		} else {
+			meta.add(key, yamlScalar(value))
+		}
+	}
+	return meta
+}
+
+// renderFrontMatter draws the header shown above the document, as lines of
+// the given width: the title, byline and tags, or every field when full is
+// set
+func renderFrontMatter(meta frontMatter, colors *ColorConfig, width int, full bool) []string {
+	if len(meta.fields) == 0 {
+		return nil
+	}
+	paint := func(seq, s string) string {
+		return seq + s + sgrReset
+	}
+	wrap := func(s string, indent int) []string {
+		pad := strings.Repeat(" ", indent)
+		wrapped, _ := text.WrapWithPadIndent(s, width, pad, pad)
+		return strings.Split(wrapped, "\n")
+	}
+
+	var lines []string
+	if full {
+		keyWidth := 0
+		for _, field := range meta.fields {
+			keyWidth = max(keyWidth, len(field.key))
+		}
+		for _, field := range meta.fields {
+			value := strings.Join(field.values, ", ")
+			for i, line := range wrap(value, keyWidth+2) {
+				if i == 0 {
+					line = paint("\x1b[1m"+colors.GetANSIColor(colors.Bold), field.key+":") + line[len(field.key)+1:]
+				}
+				lines = append(lines, line)
+			}
+		}
+	} else {
+		if title := meta.title(); title != "" {
+			for _, line := range wrap(title, 0) {
+				lines = append(lines, paint("\x1b[1m"+colors.GetANSIColor(colors.Heading1), line))
+			}
+		}
+		var byline []string
+		if author := meta.get("author", "authors"); author != nil {
+			byline = append(byline, strings.Join(author, ", "))
+		}
+		if date := meta.get("date"); date != nil {
+			byline = append(byline, date[0])
+		}
+		if byline != nil {
+			for _, line := range wrap(strings.Join(byline, " · "), 0) {
+				lines = append(lines, paint(colors.GetANSIColor(colors.Italic), line))
+			}
+		}
+		var tags []string
+		for _, tag := range meta.get("tags", "keywords", "categories") {
+			tags = append(tags, "#"+strings.ReplaceAll(tag, " ", "-"))
+		}
+		if tags != nil {
+			for _, line := range wrap(strings.Join(tags, " "), 0) {
+				lines = append(lines, paint(colors.GetANSIColor(colors.ListMarker), line))
+			}
+		}
+		if lines == nil {
+			return nil
+		}
+	}
+
+	return append(lines, paint(colors.GetANSIColor(colors.TableBorder), strings.Repeat("─", width)), "")
+}
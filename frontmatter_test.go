@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		fields   []metaField
+		body     string
+	}{
+		{
+			name:     "yaml",
+			markdown: "---\ntitle: \"Notes: part 1\"\nauthor:\n  name: Ada\ntags: [go, cli]\nsummary: >\n  Two\n  lines\n---\n# Body\n",
+			fields: []metaField{
+				{"title", []string{"Notes: part 1"}},
+				{"author.name", []string{"Ada"}},
+				{"tags", []string{"go", "cli"}},
+				{"summary", []string{"Two lines"}},
+			},
+			body: "# Body\n",
+		},
+		{
+			name:     "yaml block list",
+			markdown: "---\ncategories:\n  - a\n  - 'b'\n...\nBody",
+			fields:   []metaField{{"categories", []string{"a", "b"}}},
+			body:     "Body",
+		},
+		{
+			name:     "toml",
+			markdown: "+++\ntitle = \"Hello\" # comment\ntags = [\"x\", \"y\"]\n[params]\ndraft = true\n+++\nBody",
+			fields: []metaField{
+				{"title", []string{"Hello"}},
+				{"tags", []string{"x", "y"}},
+				{"params.draft", []string{"true"}},
+			},
+			body: "Body",
+		},
+		{
+			name:     "horizontal rules",
+			markdown: "---\nSome text\n---\n",
+			body:     "---\nSome text\n---\n",
+		},
+		{
+			name:     "unclosed",
+			markdown: "---\ntitle: x\n",
+			body:     "---\ntitle: x\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body := splitFrontMatter(tt.markdown)
+			if !reflect.DeepEqual(meta.fields, tt.fields) {
+				t.Errorf("splitFrontMatter() fields = %q, expected %q", meta.fields, tt.fields)
+			}
+			if body != tt.body {
+				t.Errorf("splitFrontMatter() body = %q, expected %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestFrontMatterHeader(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	raw := "---\ntitle: Release notes\nauthor: Ada\ndate: 2024-05-01\ntags: [go, tui]\ndraft: true\n---\n# Changes\n"
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 80
+	m.height = 20
+	m.renderedContent = m.render()
+
+	rendered := stripANSI(string(m.renderedContent))
+	for _, want := range []string{"Release notes", "Ada · 2024-05-01", "#go #tui", "Changes"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("Expected %q in the header, got:\n%s", want, rendered)
+		}
+	}
+	if strings.Contains(rendered, "title:") || strings.Contains(rendered, "draft") {
+		t.Errorf("Expected the front matter not to be rendered as text, got:\n%s", rendered)
+	}
+	if !strings.Contains(m.renderStatusBar(), "Release notes") {
+		t.Errorf("Expected the status bar to show the title, got %q", m.renderStatusBar())
+	}
+
+	updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	m = updated.(model)
+	rendered = stripANSI(string(m.renderedContent))
+	if !strings.Contains(rendered, "draft:  true") || !strings.Contains(rendered, "tags:   go, tui") {
+		t.Errorf("Expected every front matter field, got:\n%s", rendered)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+// Heading navigation moves the top of the screen from heading to heading of
+// the index built by render, in the manner of vim-markdown: ]] and [[ go to
+// the next and previous heading, ][ to the next heading of the same level in
+// the same section and ]u to the heading of the enclosing section. The
+// current section is the one holding the top of the screen.
+
+// goToHeadingLine puts the heading at line at the top of the screen, or as
+// close to it as the end of the document allows
+func (m model) goToHeadingLine(line int) model {
+	m.yOffset = max(min(line, m.lines-m.height+1), 0)
+	return m.updateLinkPositions()
+}
+
+// nextHeading goes to the first heading below the top of the screen
+func (m model) nextHeading() model {
+	for _, h := range m.headings {
+		if h.line > m.yOffset {
+			return m.goToHeadingLine(h.line)
+		}
+	}
+	return m
+}
+
+// prevHeading goes to the last heading above the top of the screen
+func (m model) prevHeading() model {
+	for i := len(m.headings) - 1; i >= 0; i-- {
+		if m.headings[i].line < m.yOffset {
+			return m.goToHeadingLine(m.headings[i].line)
+		}
+	}
+	return m
+}
+
+// nextSiblingHeading goes to the next heading of the level of the current
+// section, unless the enclosing section ends first
+func (m model) nextSiblingHeading() model {
+	current := m.currentSection()
+	if current < 0 {
+		return m.nextHeading()
+	}
+	level := m.headings[current].level
+	for _, h := range m.headings[current+1:] {
+		if h.level < level {
+			break
+		}
+		if h.level == level {
+			return m.goToHeadingLine(h.line)
+		}
+	}
+	return m
+}
+
+// parentHeading goes to the heading of the section enclosing the current
+// one
+func (m model) parentHeading() model {
+	current := m.currentSection()
+	if current < 0 {
+		return m
+	}
+	level := m.headings[current].level
+	for i := current - 1; i >= 0; i-- {
+		if m.headings[i].level < level {
+			return m.goToHeadingLine(m.headings[i].line)
+		}
+	}
+	return m
+}
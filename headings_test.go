@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestHeadingNavigation(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	filler := strings.Repeat("Filler.\n\n", 6)
+	raw := "# One\n\n" + filler + "## Two\n\n" + filler + "### Three\n\n" + filler + "## Four\n\n" + filler + "# Five\n\n" + strings.Repeat(filler, 4)
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 80
+	m.height = 10
+	m = m.refresh()
+	if len(m.headings) != 5 {
+		t.Fatalf("Expected 5 headings, got %+v", m.headings)
+	}
+	line := func(i int) int { return m.headings[i].line }
+
+	press := func(keys string) {
+		for _, r := range keys {
+			updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			m = updated.(model)
+		}
+	}
+
+	tests := []struct {
+		keys     string
+		expected int
+	}{
+		{"]]", 1},
+		{"]]", 2},
+		{"]u", 1},
+		{"][", 3},
+		{"][", 3}, // no sibling left in the section of One
+		{"[[", 2},
+		{"]u", 1},
+		{"]u", 0},
+		{"][", 4},
+	}
+	for _, tt := range tests {
+		press(tt.keys)
+		if m.yOffset != line(tt.expected) {
+			t.Errorf("After %q, yOffset = %d, expected %d (heading %q)", tt.keys, m.yOffset, line(tt.expected), m.headings[tt.expected].title)
+		}
+	}
+
+	// A key that doesn't complete a sequence drops it
+	press("]x")
+	if m.pendingHint() != "" || m.yOffset != line(4) {
+		t.Errorf("Expected the sequence dropped, got pending %q at %d", m.pendingHint(), m.yOffset)
+	}
+}
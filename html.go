@@ -0,0 +1,218 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	text "github.com/MichaelMure/go-term-text"
+)
+
+// READMEs mix in HTML for what markdown can't express. processHTML turns the
+// common inline tags into markdown before rendering: emphasis, links, line
+// breaks, super- and subscripts, images, and keycaps, which are wrapped in
+// keyMarker and styled after rendering. Layout-only wrappers such as
+// <p align="center"> are dropped and their content kept. Blocks opened by
+// other tags, such as tables, are left to the renderer.
+
+// keyMarker is a private use character that never appears in documents
+const keyMarker = "\uE003"
+
+var (
+	htmlCommentPattern = regexp.MustCompile(`<!--.*?-->`)
+	htmlPairPattern    = regexp.MustCompile(`(?i)<(kbd|sup|sub|summary)(?:\s[^>]*)?>([^<]*)</(kbd|sup|sub|summary)\s*>`)
+	htmlLinkPattern    = regexp.MustCompile(`(?i)<a\s[^>]*?href\s*=\s*("[^"]*"|'[^']*')[^>]*>(.*?)</a\s*>`)
+	htmlTagPattern     = regexp.MustCompile(`(?i)<(/?)([a-z][a-z0-9]*)((?:\s[^>]*?)?)\s*/?>`)
+	htmlAttrPattern    = regexp.MustCompile(`([a-zA-Z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	htmlBlockPattern   = regexp.MustCompile(`(?i)^ {0,3}</?(table|thead|tbody|tr|td|th|ul|ol|li|dl|dt|dd|pre|blockquote|h[1-6]|hr|form|iframe|video|audio|script|style)[\s/>]`)
+	keyPattern         = regexp.MustCompile(keyMarker + `([^` + keyMarker + `]*)` + keyMarker)
+
+	markdownEscapePattern = regexp.MustCompile("[\\\\`*_{}\\[\\]()#+\\-.!:|&<>~]")
+)
+
+// htmlEmphasis maps inline formatting tags to their markdown delimiter
+var htmlEmphasis = map[string]string{
+	"b":      "**",
+	"strong": "**",
+	"i":      "*",
+	"em":     "*",
+	"s":      "~~",
+	"del":    "~~",
+	"strike": "~~",
+}
+
+// htmlWrappers are the tags only used for layout, whose content is kept
+var htmlWrappers = map[string]bool{
+	"p":       true,
+	"div":     true,
+	"center":  true,
+	"span":    true,
+	"font":    true,
+	"small":   true,
+	"picture": true,
+	"details": true,
+	"a":       true,
+}
+
+// processHTML converts the inline HTML of markdown outside code
+func processHTML(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	var fences codeFences
+	block := false
+	for i, line := range lines {
+		if fences.inCode(line) {
+			continue
+		}
+
+		// HTML blocks run until a blank line
+		if strings.TrimSpace(line) == "" {
+			block = false
+			continue
+		}
+		if block || htmlBlockPattern.MatchString(line) {
+			block = true
+			continue
+		}
+
+		line = outsideCodeSpans(line, convertHTML)
+		// A line break ending the line is marked by its trailing spaces
+		if trimmed := strings.TrimRight(line, " \t"); strings.HasSuffix(trimmed, "  \n") {
+			line = strings.TrimSuffix(trimmed, "\n")
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// outsideCodeSpans applies convert to the parts of line outside code spans
+func outsideCodeSpans(line string, convert func(string) string) string {
+	var sb strings.Builder
+	start := 0
+	for i := 0; i < len(line); i++ {
+		if line[i] != '`' {
+			continue
+		}
+		run := len(line[i:]) - len(strings.TrimLeft(line[i:], "`"))
+		end := strings.Index(line[i+run:], line[i:i+run])
+		if end < 0 {
+			i += run - 1
+			continue
+		}
+		sb.WriteString(convert(line[start:i]))
+		sb.WriteString(line[i : i+run+end+run])
+		i += run + end + run - 1
+		start = i + 1
+	}
+	sb.WriteString(convert(line[start:]))
+	return sb.String()
+}
+
+// convertHTML converts the HTML tags of s
+func convertHTML(s string) string {
+	s = htmlCommentPattern.ReplaceAllString(s, "")
+
+	s = htmlPairPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		match := htmlPairPattern.FindStringSubmatch(tag)
+		name := strings.ToLower(match[1])
+		if name != strings.ToLower(match[3]) {
+			return tag
+		}
+		content := strings.TrimSpace(match[2])
+		switch name {
+		case "kbd":
+			return keyMarker + strings.ReplaceAll(markdownEscape(content), " ", "\u00a0") + keyMarker
+		case "sup":
+			return markdownEscape(scripted(content, superscripts, "^"))
+		case "sub":
+			return markdownEscape(scripted(content, subscripts, "_"))
+		}
+		return "**" + content + "**"
+	})
+
+	s = htmlLinkPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		match := htmlLinkPattern.FindStringSubmatch(tag)
+		return "[" + match[2] + "](" + strings.Trim(match[1], `"'`) + ")"
+	})
+
+	return htmlTagPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		match := htmlTagPattern.FindStringSubmatch(tag)
+		name := strings.ToLower(match[2])
+		switch {
+		case name == "br":
+			return "  \n"
+		case name == "img":
+			return htmlImage(htmlAttributes(match[3]))
+		case htmlEmphasis[name] != "":
+			return htmlEmphasis[name]
+		case htmlWrappers[name]:
+			return ""
+		}
+		return tag
+	})
+}
+
+// htmlAttributes returns the attributes of a tag by lowercase name
+func htmlAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range htmlAttrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(match[1])] = strings.Trim(match[2], `"'`)
+	}
+	return attrs
+}
+
+// htmlImage returns the markdown image of an <img> tag when its source can
+// be loaded, and a placeholder with its alt text otherwise
+func htmlImage(attrs map[string]string) string {
+	src := attrs["src"]
+	alt := attrs["alt"]
+	if alt == "" {
+		alt = attrs["title"]
+	}
+
+	remote := strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+	if _, err := os.Stat(src); src != "" && (remote || err == nil) {
+		return "![" + markdownEscape(alt) + "](" + src + ")"
+	}
+	if alt == "" {
+		return "*\\[image\\]*"
+	}
+	return "*\\[image: " + markdownEscape(alt) + "\\]*"
+}
+
+// markdownEscape escapes the characters of s markdown would interpret
+func markdownEscape(s string) string {
+	return markdownEscapePattern.ReplaceAllString(s, `\$0`)
+}
+
+// styleKeys draws the <kbd> keys of the rendered document as keycaps, on the
+// code block background, or in brackets when there is none
+func styleKeys(rendered []byte, colors *ColorConfig) []byte {
+	if !strings.Contains(string(rendered), keyMarker) {
+		return rendered
+	}
+	background := colors.GetANSIBackground(colors.CodeBlockBg)
+	lines := strings.Split(string(rendered), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, keyMarker) {
+			continue
+		}
+		var sb strings.Builder
+		var state text.EscapeState
+		last := 0
+		for _, match := range keyPattern.FindAllStringSubmatchIndex(line, -1) {
+			before := line[last:match[0]]
+			state.Witness(before)
+			sb.WriteString(before)
+			key := line[match[2]:match[3]]
+			if background != "" {
+				sb.WriteString("\x1b[1m" + colors.GetANSIColor(colors.Code) + background + " " + key + " " + sgrReset + state.String())
+			} else {
+				sb.WriteString("[" + key + "]")
+			}
+			last = match[1]
+		}
+		sb.WriteString(line[last:])
+		lines[i] = sb.String()
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProcessHTML(t *testing.T) {
+	tests := []struct {
+		markdown string
+		expected string
+	}{
+		{"Press <kbd>Ctrl</kbd>+<kbd>Page Up</kbd>", "Press " + keyMarker + "Ctrl" + keyMarker + "+" + keyMarker + "Page\u00a0Up" + keyMarker},
+		{"H<sub>2</sub>O and x<sup>n+1</sup> or a<sup>*</sup>", "H₂O and xⁿ⁺¹ or a^\\*"},
+		{"One<br>two<br/>\nthree<br />", "One  \ntwo  \nthree  "},
+		{"<b>Bold</b>, <em>it</em> and <del>old</del>", "**Bold**, *it* and ~~old~~"},
+		{`<a href="https://example.com">site</a> <a name="top"></a>`, "[site](https://example.com) "},
+		{`<a href="https://x.org"><img src="https://img.shields.io/badge/a-b-green" alt="a"></a>`, "[![a](https://img.shields.io/badge/a-b-green)](https://x.org)"},
+		{"<p align=\"center\">\n  <img src=\"/missing.png\" alt=\"Shot 1\" width=\"45%\" />\n</p>", "\n  *\\[image: Shot 1\\]*\n"},
+		{"<details>\n<summary>More</summary>\n\nText <!-- note -->\n</details>", "\n**More**\n\nText \n"},
+		{"Code `<kbd>x</kbd>` <kbd>y</kbd>", "Code `<kbd>x</kbd>` " + keyMarker + "y" + keyMarker},
+		{"```html\n<br>\n```", "```html\n<br>\n```"},
+		{"<table>\n<tr><td><b>x</b></td></tr>\n</table>\n\n<b>y</b>", "<table>\n<tr><td><b>x</b></td></tr>\n</table>\n\n**y**"},
+	}
+
+	for _, tt := range tests {
+		if got := processHTML(tt.markdown); got != tt.expected {
+			t.Errorf("processHTML(%q) = %q, expected %q", tt.markdown, got, tt.expected)
+		}
+	}
+}
+
+func TestStyleKeys(t *testing.T) {
+	useColorProfile(t, ProfileTrueColor)
+
+	colors := &DefaultConfig().Colors
+	line := "\x1b[31mPress " + keyMarker + "Ctrl" + keyMarker + " now\x1b[0m"
+	got := string(styleKeys([]byte(line), colors))
+	if stripANSI(got) != "Press  Ctrl  now" {
+		t.Errorf("styleKeys() text = %q, expected %q", stripANSI(got), "Press  Ctrl  now")
+	}
+	if !strings.Contains(got, colors.GetANSIBackground(colors.CodeBlockBg)+" Ctrl "+sgrReset+"\x1b[31m") {
+		t.Errorf("Expected a keycap followed by the surrounding style, got %q", got)
+	}
+
+	colors.CodeBlockBg = ""
+	if got := stripANSI(string(styleKeys([]byte(line), colors))); got != "Press [Ctrl] now" {
+		t.Errorf("styleKeys() without background = %q, expected %q", got, "Press [Ctrl] now")
+	}
+}
@@ -100,7 +100,7 @@ func addHyperlinks(rendered []byte, originalMarkdown string, config *Config, hov
 		}
 		
 		// Validate it looks like a URL
-		if !strings.Contains(url, "://") && !strings.HasPrefix(url, "mailto:") {
+		if !strings.Contains(url, "://") && !strings.HasPrefix(url, "mailto:") && !isFootnoteURL(url) && !isDetailsURL(url) {
 			// Not a URL, might be something else, don't convert
 			continue
 		}
@@ -118,6 +118,17 @@ func addHyperlinks(rendered []byte, originalMarkdown string, config *Config, hov
 		// \x1b[59m = default underline color
 		var hyperlinked string
 		
+		// Color the link text, hyperlink_text takes precedence over link
+		if config != nil {
+			textColor := config.Colors.HyperlinkText
+			if textColor == "" {
+				textColor = config.Colors.Link
+			}
+			if colorSeq := config.Colors.GetANSIColor(textColor); colorSeq != "" {
+				text = colorSeq + text + "\x1b[39m"
+			}
+		}
+		
 		// Check if this URL is being hovered
 		isHovered := hoveredURL != "" && url == hoveredURL
 		
@@ -130,13 +141,13 @@ func addHyperlinks(rendered []byte, originalMarkdown string, config *Config, hov
 		}
 		
 		if underlineColor != "" {
-			if colorCode, err := hexToANSI(underlineColor); err == nil {
+			if colorSeq, err := colorSequence(underlineColor, 58); err == nil && colorSeq != "" {
 				// Use CSI 58 for underline color, CSI 4 for underline style
 				// Set underline color before the OSC 8 sequence so it applies to the entire link
 				// Format: <underline-color><underline-on><OSC8-start>text<OSC8-end><underline-off><underline-color-reset>
-				hyperlinked = fmt.Sprintf("\x1b[58;5;%dm\x1b[4m\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\\x1b[59m\x1b[24m", colorCode, url, text)
+				hyperlinked = fmt.Sprintf("%s\x1b[4m\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\\x1b[59m\x1b[24m", colorSeq, url, text)
 			} else {
-				// Fallback to default underline if color parsing fails
+				// Fallback to default underline if color parsing fails or colors are disabled
 				hyperlinked = fmt.Sprintf("\x1b[4m\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\\x1b[24m", url, text)
 			}
 		} else {
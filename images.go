@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Images standing alone on their line are drawn with a terminal graphics
+// protocol when the terminal has one: kitty, iTerm2 inline images or sixel.
+// processImages replaces them with a placeholder paragraph, and insertImages
+// reserves rows for the image in its place after wrapping. The image itself
+// is sent on the last of these rows, drawn upward from there with the cursor
+// saved and restored around it, so that the rows above are already painted
+// when it shows up. Images only partly on screen are hidden by showImages.
+// Other images are left to go-term-markdown, which draws them with blocks.
+
+// Values of the images setting
+const (
+	imagesAuto     = "auto"     // a graphics protocol if the terminal has one, else blocks
+	imagesProtocol = "protocol" // a graphics protocol, sixel if none is detected
+	imagesBlocks   = "blocks"   // colored half blocks
+	imagesAltText  = "alt-text" // the alt text of the image
+	imagesOff      = "off"      // nothing
+)
+
+// Terminal graphics protocols
+const (
+	protocolKitty = "kitty"
+	protocolITerm = "iTerm2"
+	protocolSixel = "sixel"
+)
+
+// The cell size in pixels assumed when the terminal doesn't report it, and
+// the rows images may take before the size of the screen is known
+const (
+	defaultCellWidth  = 10
+	defaultCellHeight = 20
+	defaultImageRows  = 20
+)
+
+// imageMarker is a private use character that never appears in documents
+const imageMarker = "\uE004"
+
+var (
+	imageLinePattern   = regexp.MustCompile(`^ {0,3}(?:!\[[^\]]*\]\([^)\s]+(?:\s+"[^"]*")?\)\s*)+$`)
+	imageRefPattern    = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	imagePattern       = regexp.MustCompile(imageMarker + `(\d+)`)
+	imageEscapePattern = regexp.MustCompile(`\x1b7(?:\x1b\[(\d+)A)?((?:\x1b[P_\]][^\x07\x1b]*(?:\x07|\x1b\\))+)\x1b8`)
+	deviceAttrsPattern = regexp.MustCompile(`\x1b\[\?([\d;]*)c`)
+)
+
+// markdownImage is an image drawn with a graphics protocol
+type markdownImage struct {
+	alt string
+	img image.Image
+	key string
+}
+
+// terminalGraphics describes the graphics protocol of the terminal
+type terminalGraphics struct {
+	// protocol is empty when the terminal has none
+	protocol string
+	// source tells how the protocol was found
+	source string
+}
+
+// String describes the graphics protocol for --config-path
+func (g terminalGraphics) String() string {
+	if g.protocol == "" {
+		return "none (" + g.source + ")"
+	}
+	return fmt.Sprintf("%s (from %s)", g.protocol, g.source)
+}
+
+// detectedGraphics caches the result of the first graphics protocol detection
+var detectedGraphics *terminalGraphics
+
+// currentGraphics returns the graphics protocol of the terminal, detecting it
+// on first use. The terminal can't be queried once the UI runs, so main calls
+// it first.
+func currentGraphics() terminalGraphics {
+	if detectedGraphics == nil {
+		g := detectGraphics()
+		detectedGraphics = &g
+	}
+	return *detectedGraphics
+}
+
+// detectGraphics finds the graphics protocol of the terminal from its
+// environment, then by asking whether it supports sixel
+func detectGraphics() terminalGraphics {
+	if os.Getenv("TMUX") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		// Multiplexers don't pass images through
+		return terminalGraphics{source: "multiplexer"}
+	}
+
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "":
+		return terminalGraphics{protocolKitty, "KITTY_WINDOW_ID"}
+	case os.Getenv("TERM") == "xterm-kitty", os.Getenv("TERM") == "xterm-ghostty":
+		return terminalGraphics{protocolKitty, "TERM"}
+	case os.Getenv("TERM_PROGRAM") == "ghostty":
+		return terminalGraphics{protocolKitty, "TERM_PROGRAM"}
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app", os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return terminalGraphics{protocolITerm, "TERM_PROGRAM"}
+	case os.Getenv("LC_TERMINAL") == "iTerm2":
+		return terminalGraphics{protocolITerm, "LC_TERMINAL"}
+	}
+
+	// The primary device attributes list 4 for sixel graphics
+	if reply, err := queryTerminal("\x1b[c", backgroundQueryTimeout); err == nil {
+		if match := deviceAttrsPattern.FindSubmatch(reply); match != nil {
+			for _, attr := range strings.Split(string(match[1]), ";") {
+				if attr == "4" {
+					return terminalGraphics{protocolSixel, "device attributes"}
+				}
+			}
+		}
+	}
+	return terminalGraphics{source: "not detected"}
+}
+
+// graphicsProtocol returns the protocol images are drawn with for the images
+// setting, or an empty string to leave them to go-term-markdown
+func graphicsProtocol(mode string) string {
+	switch mode {
+	case imagesAuto:
+		return currentGraphics().protocol
+	case imagesProtocol:
+		if protocol := currentGraphics().protocol; protocol != "" {
+			return protocol
+		}
+		return protocolSixel
+	}
+	return ""
+}
+
+// processImages handles the images of markdown as set by mode. Standalone
+// local images become placeholders when protocol is set; images are replaced
+// by their alt text or dropped in the alt-text and off modes. Badges are left
+// to processBadges.
+func processImages(markdown, mode, protocol string) (string, []markdownImage) {
+	if mode == imagesBlocks || (mode != imagesAltText && mode != imagesOff && protocol == "") {
+		return markdown, nil
+	}
+
+	lines := strings.Split(markdown, "\n")
+	var out []string
+	var images []markdownImage
+	var fences codeFences
+	for _, line := range lines {
+		if fences.inCode(line) {
+			out = append(out, line)
+			continue
+		}
+
+		if mode == imagesAltText || mode == imagesOff {
+			out = append(out, outsideCodeSpans(line, func(s string) string {
+				return replaceImages(s, mode)
+			}))
+			continue
+		}
+
+		if !imageLinePattern.MatchString(line) {
+			out = append(out, line)
+			continue
+		}
+		for _, match := range imageRefPattern.FindAllStringSubmatch(line, -1) {
+			img, err := loadImage(match[2])
+			if err != nil {
+				out = append(out, "", match[0], "")
+				continue
+			}
+			out = append(out, "", imageMarker+strconv.Itoa(len(images)), "")
+			images = append(images, markdownImage{alt: match[1], img: img, key: match[2]})
+		}
+	}
+	return strings.Join(out, "\n"), images
+}
+
+// replaceImages replaces the images of s with their alt text, or drops them
+// in the off mode. Images inside links keep their alt text as link text.
+func replaceImages(s, mode string) string {
+	var sb strings.Builder
+	last := 0
+	for _, match := range imageRefPattern.FindAllStringSubmatchIndex(s, -1) {
+		sb.WriteString(s[last:match[0]])
+		alt, src := s[match[2]:match[3]], s[match[4]:match[5]]
+		linked := match[0] > 0 && s[match[0]-1] == '['
+		switch {
+		case isBadgeURL(src):
+			sb.WriteString(s[match[0]:match[1]])
+		case linked && mode == imagesOff:
+			sb.WriteString(alt)
+		case mode == imagesAltText:
+			sb.WriteString(imagePlaceholder(alt))
+		}
+		last = match[1]
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// imagePlaceholder returns the markdown shown in place of an image
+func imagePlaceholder(alt string) string {
+	if alt == "" {
+		return "*\\[image\\]*"
+	}
+	return "*\\[image: " + markdownEscape(alt) + "\\]*"
+}
+
+// decodedImages caches the images read from disk by path
+var decodedImages = make(map[string]image.Image)
+
+// loadImage reads a local PNG, JPEG or GIF image
+func loadImage(src string) (image.Image, error) {
+	if strings.Contains(src, "://") {
+		return nil, fmt.Errorf("not a local image: %s", src)
+	}
+	if img, ok := decodedImages[src]; ok {
+		return img, nil
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	decodedImages[src] = img
+	return img, nil
+}
+
+// insertImages replaces the image placeholders of the rendered document with
+// the rows of the images, scaled to the width of the text and at most
+// maxRows high. The first row shows the alt text until the image covers it.
+func insertImages(rendered []byte, images []markdownImage, protocol string, width, maxRows int, style string) []byte {
+	if len(images) == 0 {
+		return rendered
+	}
+	return replacePlaceholders(rendered, imagePattern, func(n, indent int) []string {
+		if n >= len(images) {
+			return nil
+		}
+		pad := strings.Repeat(" ", indent)
+		escape, rows := drawImage(images[n], protocol, max(1, width-indent), maxRows)
+
+		lines := make([]string, rows)
+		for i := range lines {
+			lines[i] = pad
+		}
+		if rows > 1 {
+			lines[0] = pad + style + "[" + images[n].alt + "]" + sgrReset
+		}
+		cursorUp := ""
+		if rows > 1 {
+			cursorUp = fmt.Sprintf("\x1b[%dA", rows-1)
+		}
+		lines[rows-1] = pad + "\x1b7" + cursorUp + escape + "\x1b8"
+		return lines
+	})
+}
+
+// encodedImages caches the escape sequences of the images drawn so far
+var encodedImages = make(map[string]string)
+
+// drawImage returns the escape sequence drawing img at the cursor with
+// protocol, at most cols wide and maxRows high, and the number of rows it takes
+func drawImage(img markdownImage, protocol string, cols, maxRows int) (string, int) {
+	cellWidth, cellHeight := cellSize()
+	bounds := img.img.Bounds()
+	w, h := max(1, bounds.Dx()), max(1, bounds.Dy())
+
+	// Images are not scaled up past their size
+	cols = min(cols, (w+cellWidth-1)/cellWidth)
+	rows := (cols*cellWidth*h/w + cellHeight - 1) / cellHeight
+	if rows > maxRows {
+		rows = maxRows
+		cols = max(1, rows*cellHeight*w/h/cellWidth)
+	}
+	rows = max(1, rows)
+
+	key := fmt.Sprintf("%s %s %dx%d", img.key, protocol, cols, rows)
+	if escape, ok := encodedImages[key]; ok {
+		return escape, rows
+	}
+
+	// Pixels past the size the image is shown at are never seen
+	pw := min(w, cols*cellWidth)
+	ph := max(1, min(min(h, pw*h/w), rows*cellHeight))
+	scaled := image.Image(img.img)
+	if pw != w || ph != h {
+		dst := image.NewRGBA(image.Rect(0, 0, pw, ph))
+		draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img.img, bounds, draw.Src, nil)
+		scaled = dst
+	}
+
+	var escape string
+	switch protocol {
+	case protocolKitty:
+		escape = kittyImage(scaled, cols, rows)
+	case protocolITerm:
+		escape = iTermImage(scaled, cols, rows)
+	default:
+		escape = sixelImage(scaled)
+	}
+	encodedImages[key] = escape
+	return escape, rows
+}
+
+// encodePNG returns img as base64 encoded PNG data
+func encodePNG(img image.Image) string {
+	var buf bytes.Buffer
+	png.Encode(&buf, img) //nolint:errcheck // writing to memory
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// kittyImage draws img over cols×rows cells with the kitty graphics
+// protocol, without moving the cursor or answering back
+func kittyImage(img image.Image, cols, rows int) string {
+	data := encodePNG(img)
+	var sb strings.Builder
+	const chunk = 4096
+	for start := 0; start < len(data); start += chunk {
+		end := min(start+chunk, len(data))
+		more := 0
+		if end < len(data) {
+			more = 1
+		}
+		if start == 0 {
+			fmt.Fprintf(&sb, "\x1b_Ga=T,f=100,t=d,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", cols, rows, more, data[start:end])
+		} else {
+			fmt.Fprintf(&sb, "\x1b_Gm=%d;%s\x1b\\", more, data[start:end])
+		}
+	}
+	return sb.String()
+}
+
+// iTermImage draws img over cols×rows cells as an iTerm2 inline image
+func iTermImage(img image.Image, cols, rows int) string {
+	data := encodePNG(img)
+	return fmt.Sprintf("\x1b]1337;File=inline=1;width=%d;height=%d;preserveAspectRatio=1:%s\x07", cols, rows, data)
+}
+
+// sixelImage draws img with sixel graphics, in the 256 colors of the Plan 9
+// palette. Transparent pixels are left alone.
+func sixelImage(img image.Image) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	paletted := image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\x1bP0;1;0q\"1;1;%d;%d", w, h)
+	for i, c := range paletted.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(&sb, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	// Each band of six pixel rows is drawn one color at a time
+	masks := make(map[uint8][]byte)
+	var used []uint8
+	for y := 0; y < h; y += 6 {
+		for _, index := range used {
+			delete(masks, index)
+		}
+		used = used[:0]
+		for x := 0; x < w; x++ {
+			for dy := 0; dy < 6 && y+dy < h; dy++ {
+				if _, _, _, a := img.At(bounds.Min.X+x, bounds.Min.Y+y+dy).RGBA(); a < 0x8000 {
+					continue
+				}
+				index := paletted.ColorIndexAt(x, y+dy)
+				mask, ok := masks[index]
+				if !ok {
+					mask = make([]byte, w)
+					masks[index] = mask
+					used = append(used, index)
+				}
+				mask[x] |= 1 << dy
+			}
+		}
+
+		for i, index := range used {
+			if i > 0 {
+				sb.WriteByte('$')
+			}
+			fmt.Fprintf(&sb, "#%d", index)
+			mask := masks[index]
+			for x := 0; x < w; {
+				run := 1
+				for x+run < w && mask[x+run] == mask[x] {
+					run++
+				}
+				c := byte('?' + mask[x])
+				if run > 3 {
+					fmt.Fprintf(&sb, "!%d%c", run, c)
+				} else {
+					sb.Write(bytes.Repeat([]byte{c}, run))
+				}
+				x += run
+			}
+		}
+		sb.WriteByte('-')
+	}
+	sb.WriteString("\x1b\\")
+	return sb.String()
+}
+
+// imageRows returns the most rows an image may take, so that it fits on
+// screen
+func (m model) imageRows() int {
+	if m.height == 0 {
+		// Not known before the first WindowSizeMsg
+		return defaultImageRows
+	}
+	return max(1, m.height-4)
+}
+
+// showImages hides the images of the visible lines that are not wholly on
+// screen: those whose first rows are above the first line, and those cut by
+// horizontal scrolling. The lines of the others are filled to the width of
+// the screen before the image is drawn, as the renderer would otherwise erase
+// the end of the line after it.
+func showImages(lines []string, xOffset, width int) []string {
+	for i, line := range lines {
+		match := imageEscapePattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		up := 0
+		if match[2] >= 0 {
+			up, _ = strconv.Atoi(line[match[2]:match[3]])
+		}
+		indent := match[0]
+		if i-up < 0 || xOffset > indent {
+			lines[i] = line[:match[0]] + line[match[1]:]
+			continue
+		}
+
+		// The cursor goes back to the start of the image from the end of the line
+		var sb strings.Builder
+		sb.WriteString(strings.Repeat(" ", width+xOffset))
+		sb.WriteString("\x1b7\r")
+		if indent > xOffset {
+			fmt.Fprintf(&sb, "\x1b[%dC", indent-xOffset)
+		}
+		sb.WriteString(line[match[0]+2 : match[1]])
+		lines[i] = sb.String()
+	}
+	return lines
+}
+
+// hideImages removes every image from the lines
+func hideImages(lines []string) []string {
+	for i, line := range lines {
+		lines[i] = imageEscapePattern.ReplaceAllString(line, "")
+	}
+	return lines
+}
+
+// imagesOnScreen describes where images are drawn in the current view, to
+// tell when they move. Terminals keep images drawn until the screen is
+// cleared, so it is cleared then.
+func (m model) imagesOnScreen() string {
+	if m.helpActive || !bytes.Contains(m.renderedContent, []byte("\x1b7")) {
+		return ""
+	}
+	var sb strings.Builder
+	for i, line := range strings.Split(string(m.renderedContent), "\n") {
+		match := imageEscapePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		up, _ := strconv.Atoi(match[1])
+		if i >= m.yOffset && i-up < m.yOffset+m.height {
+			fmt.Fprintf(&sb, "%d ", i-m.yOffset)
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	fmt.Fprintf(&sb, "%d %t %t", m.xOffset, m.searchActive, m.search.term != "")
+	return sb.String()
+}
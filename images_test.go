@@ -0,0 +1,135 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestImage writes a w×h PNG image to a temporary directory
+func writeTestImage(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), 128, 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "test.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessImages(t *testing.T) {
+	path := writeTestImage(t, 4, 4)
+	tests := []struct {
+		name     string
+		markdown string
+		mode     string
+		protocol string
+		expected string
+		images   int
+	}{
+		{"protocol", "Text\n![Chart](" + path + ")\nMore", imagesAuto, protocolKitty, "Text\n\n" + imageMarker + "0\n\nMore", 1},
+		{"remote", "![Logo](https://example.com/logo.png)", imagesAuto, protocolKitty, "\n![Logo](https://example.com/logo.png)\n", 0},
+		{"inline", "See ![Chart](" + path + ") here", imagesProtocol, protocolSixel, "See ![Chart](" + path + ") here", 0},
+		{"no protocol", "![Chart](" + path + ")", imagesAuto, "", "![Chart](" + path + ")", 0},
+		{"blocks", "![Chart](" + path + ")", imagesBlocks, "", "![Chart](" + path + ")", 0},
+		{"alt text", "A ![The chart](x.png) and [![b](y.png)](https://x.org)", imagesAltText, "", "A *\\[image: The chart\\]* and [*\\[image: b\\]*](https://x.org)", 0},
+		{"off", "A ![The chart](x.png) and [![b](y.png)](https://x.org)", imagesOff, "", "A  and [b](https://x.org)", 0},
+		{"badge", "![build](https://img.shields.io/badge/build-passing-green)", imagesOff, "", "![build](https://img.shields.io/badge/build-passing-green)", 0},
+		{"code", "```\n![Chart](x.png)\n```\n`![a](b.png)`", imagesOff, "", "```\n![Chart](x.png)\n```\n`![a](b.png)`", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, images := processImages(tt.markdown, tt.mode, tt.protocol)
+			if got != tt.expected {
+				t.Errorf("processImages() = %q, expected %q", got, tt.expected)
+			}
+			if len(images) != tt.images {
+				t.Errorf("processImages() returned %d images, expected %d", len(images), tt.images)
+			}
+		})
+	}
+}
+
+func TestSixelImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 7))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 7; y++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	// A transparent pixel is left out of the masks
+	img.Set(0, 0, color.RGBA{})
+
+	got := sixelImage(img)
+	if !strings.HasPrefix(got, "\x1bP0;1;0q\"1;1;8;7#0;2;") || !strings.HasSuffix(got, "-\x1b\\") {
+		t.Fatalf("sixelImage() = %q, expected a sixel sequence with raster attributes", got)
+	}
+	// Two bands: the first with its transparent corner and a run of 7 full
+	// columns, the second with a single row of pixels
+	if strings.Count(got, "-") != 2 || !strings.Contains(got, "}!7~-") || !strings.Contains(got, "!8@-") {
+		t.Errorf("sixelImage() = %q, expected two run-length encoded bands", got)
+	}
+}
+
+func TestShowImages(t *testing.T) {
+	useColorProfile(t, ProfileANSI256)
+
+	detectedGraphics = &terminalGraphics{protocol: protocolKitty, source: "test"}
+	t.Cleanup(func() { detectedGraphics = nil })
+
+	path := writeTestImage(t, 40, 60)
+	m := newModel([]byte("Intro.\n\n![Chart]("+path+")\n\nAfter.\n"), DefaultConfig())
+	m.width = 40
+	m.height = 20
+
+	lines := strings.Split(string(m.renderedContent), "\n")
+	last := -1
+	for i, line := range lines {
+		if imageEscapePattern.MatchString(line) {
+			last = i
+		}
+	}
+	// 40×60 pixels take 4 columns and 3 rows of 10×20 cells
+	if last < 0 || !strings.Contains(lines[last], "\x1b[2A\x1b_Ga=T,f=100,t=d,q=2,C=1,c=4,r=3,m=0;") {
+		t.Fatalf("Expected the image on the last of its rows, got %q", m.renderedContent)
+	}
+	if got := stripANSI(lines[last-2]); !strings.Contains(got, "[Chart]") {
+		t.Errorf("Expected the alt text on the first row of the image, got %q", got)
+	}
+	if got := stripANSI(lines[last]); strings.TrimSpace(got) != "" {
+		t.Errorf("stripANSI() of the image row = %q, expected blanks", got)
+	}
+
+	view := strings.Split(m.View(), "\n")
+	if !strings.HasPrefix(view[last], strings.Repeat(" ", m.width)+"\x1b7\r\x1b[") {
+		t.Errorf("Expected the image row to fill the screen, got %q", view[last])
+	}
+
+	// The first rows of the image are scrolled out of view
+	m.yOffset = last - 1
+	if view := m.View(); strings.Contains(view, "\x1b_G") {
+		t.Errorf("Expected the cut image to be hidden, got %q", view)
+	}
+	if m.imagesOnScreen() == "" {
+		t.Error("Expected the cut image to be tracked until it leaves the screen")
+	}
+	m.yOffset = last + 1
+	if m.imagesOnScreen() != "" {
+		t.Errorf("Expected no images on screen, got %q", m.imagesOnScreen())
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Keybindings are sequences of keys separated by spaces, such as "g g".
+// Keys are single characters, names such as "Enter" or "PageDown", or either
+// of those with modifiers: "C-" for Ctrl, "M-" for Alt and "S-" for Shift,
+// as in "C-f" and "M-j". The names bubbletea gives keys, such as "ctrl+f",
+// "alt+j" or "pgdown", work as well. Characters written together without a
+// space, as in "]]", are a sequence too.
+//
+// While the keys typed so far start a longer binding, they wait for the
+// rest of it. When the wait runs past key_timeout milliseconds, the keys
+// run the action they are bound to, if any, or are dropped. Digits typed
+// before the keys are a count, which repeats motions or, for the top and
+// bottom keys, picks the line to go to.
+
+// maxCount bounds the counts, which repeat motions one step at a time
+const maxCount = 9999
+
+// keyTimeoutMsg ends the wait for the rest of a key sequence
+type keyTimeoutMsg struct {
+	// id is the sequence the timeout was set for
+	id int
+}
+
+var functionKeyPattern = regexp.MustCompile(`^f([1-9]|1[0-9]|20)$`)
+
+// keyNames maps the names of keys in keybindings to the names bubbletea
+// gives them
+var keyNames = map[string]string{
+	"up":         "up",
+	"arrowup":    "up",
+	"down":       "down",
+	"arrowdown":  "down",
+	"left":       "left",
+	"arrowleft":  "left",
+	"right":      "right",
+	"arrowright": "right",
+	"pageup":     "pgup",
+	"pgup":       "pgup",
+	"pagedown":   "pgdown",
+	"pgdown":     "pgdown",
+	"pgdn":       "pgdown",
+	"pagedn":     "pgdown",
+	"space":      " ",
+	"escape":     "esc",
+	"esc":        "esc",
+	"enter":      "enter",
+	"return":     "enter",
+	"tab":        "tab",
+	"backspace":  "backspace",
+	"home":       "home",
+	"end":        "end",
+	"delete":     "delete",
+	"del":        "delete",
+	"insert":     "insert",
+}
+
+// normalizeKey returns the bubbletea name of a key of a keybinding. ok is
+// false when key isn't a single key.
+func normalizeKey(key string) (name string, ok bool) {
+	var alt, ctrl, shift bool
+modifiers:
+	for {
+		lower := strings.ToLower(key)
+		switch {
+		case len(key) > 2 && (strings.HasPrefix(key, "M-") || strings.HasPrefix(key, "A-")):
+			alt, key = true, key[2:]
+		case len(key) > 4 && strings.HasPrefix(lower, "alt+"):
+			alt, key = true, key[4:]
+		case len(key) > 2 && strings.HasPrefix(key, "C-"):
+			ctrl, key = true, key[2:]
+		case len(key) > 5 && strings.HasPrefix(lower, "ctrl+"):
+			ctrl, key = true, key[5:]
+		case len(key) > 2 && strings.HasPrefix(key, "S-"):
+			shift, key = true, key[2:]
+		case len(key) > 6 && strings.HasPrefix(lower, "shift+"):
+			shift, key = true, key[6:]
+		default:
+			break modifiers
+		}
+	}
+
+	switch lower := strings.ToLower(key); {
+	case len([]rune(key)) == 1:
+		// Terminals send shifted characters as they are, and Ctrl
+		// combinations without case
+		name = key
+		if shift {
+			name, shift = strings.ToUpper(key), false
+		}
+		if ctrl {
+			name = lower
+		}
+	case keyNames[lower] != "":
+		name = keyNames[lower]
+	case functionKeyPattern.MatchString(lower):
+		name = lower
+	default:
+		return "", false
+	}
+
+	if shift {
+		name = "shift+" + name
+	}
+	if ctrl {
+		name = "ctrl+" + name
+	}
+	if alt {
+		name = "alt+" + name
+	}
+	return name, true
+}
+
+// parseKeySequence returns the bubbletea names of the keys of a keybinding
+func parseKeySequence(binding string) []string {
+	if binding == " " {
+		return []string{" "}
+	}
+	var keys []string
+	for _, field := range strings.Fields(binding) {
+		if key, ok := normalizeKey(field); ok {
+			keys = append(keys, key)
+			continue
+		}
+		// Characters written together, as in "]]"
+		for _, r := range field {
+			keys = append(keys, string(r))
+		}
+	}
+	return keys
+}
+
+// keySequences returns the key sequences of all the keybindings
+func (m model) keySequences() [][]string {
+	var sequences [][]string
+	bindings := reflect.ValueOf(m.config.Keybindings)
+	for i := 0; i < bindings.NumField(); i++ {
+		for _, binding := range bindings.Field(i).Interface().([]string) {
+			sequences = append(sequences, parseKeySequence(binding))
+		}
+	}
+	return sequences
+}
+
+// matchKeys tells whether keys are a whole keybinding, and whether they
+// start a longer one
+func (m model) matchKeys(keys []string) (whole, prefix bool) {
+	for _, sequence := range m.keySequences() {
+		if len(sequence) < len(keys) || !reflect.DeepEqual(sequence[:len(keys)], keys) {
+			continue
+		}
+		if len(sequence) == len(keys) {
+			whole = true
+		} else {
+			prefix = true
+		}
+	}
+	return whole, prefix
+}
+
+// readKey adds key to the keys typed so far. It returns the keys of a
+// complete sequence, joined by spaces, and the count typed before them, or
+// "" while more keys may follow.
+func (m model) readKey(key string) (model, string, int, tea.Cmd) {
+	// Digits start a count unless a keybinding starts with them
+	if len(m.pendingKeys) == 0 && len(key) == 1 && key >= "0" && key <= "9" && (key != "0" || m.pendingCount > 0) {
+		if whole, prefix := m.matchKeys([]string{key}); !whole && !prefix {
+			m.pendingCount = min(m.pendingCount*10+int(key[0]-'0'), maxCount)
+			return m, "", 0, nil
+		}
+	}
+
+	keys := append(append([]string{}, m.pendingKeys...), key)
+	if _, prefix := m.matchKeys(keys); prefix {
+		m.pendingKeys = keys
+		m.pendingID++
+		id := m.pendingID
+		timeout := time.Duration(m.config.KeyTimeout) * time.Millisecond
+		return m, "", 0, tea.Tick(timeout, func(time.Time) tea.Msg {
+			return keyTimeoutMsg{id: id}
+		})
+	}
+
+	count := m.pendingCount
+	m.pendingKeys, m.pendingCount = nil, 0
+	return m, strings.Join(keys, " "), count, nil
+}
+
+// keyTimeout runs the keys typed so far when nothing followed them in time
+func (m model) keyTimeout(msg keyTimeoutMsg) (tea.Model, tea.Cmd) {
+	if msg.id != m.pendingID || len(m.pendingKeys) == 0 {
+		return m, nil
+	}
+	keys, count := strings.Join(m.pendingKeys, " "), m.pendingCount
+	m.pendingKeys, m.pendingCount = nil, 0
+	return m.runKeys(keys, count)
+}
+
+// pendingHint returns the count and keys typed so far, or "" when no
+// sequence is under way
+func (m model) pendingHint() string {
+	if len(m.pendingKeys) == 0 && m.pendingCount == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	if m.pendingCount > 0 {
+		sb.WriteString(strconv.Itoa(m.pendingCount))
+	}
+	for _, key := range m.pendingKeys {
+		switch {
+		case key == " ":
+			sb.WriteString("<space>")
+		case len([]rune(key)) > 1:
+			sb.WriteString("<" + key + ">")
+		default:
+			sb.WriteString(key)
+		}
+	}
+	return sb.String() + "…"
+}
+
+// repeat applies a motion count times, and once without a count
+func (m model) repeat(count int, motion func(model) model) model {
+	for i := 0; i < max(count, 1); i++ {
+		m = motion(m)
+	}
+	return m
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseKeySequence(t *testing.T) {
+	tests := []struct {
+		binding  string
+		expected []string
+	}{
+		{"j", []string{"j"}},
+		{"G", []string{"G"}},
+		{" ", []string{" "}},
+		{"Space", []string{" "}},
+		{"PageDown", []string{"pgdown"}},
+		{"ArrowUp", []string{"up"}},
+		{"Escape", []string{"esc"}},
+		{"C-f", []string{"ctrl+f"}},
+		{"ctrl+f", []string{"ctrl+f"}},
+		{"M-j", []string{"alt+j"}},
+		{"alt+j", []string{"alt+j"}},
+		{"C-M-x", []string{"alt+ctrl+x"}},
+		{"S-Tab", []string{"shift+tab"}},
+		{"S-a", []string{"A"}},
+		{"F5", []string{"f5"}},
+		{"g g", []string{"g", "g"}},
+		{"C-x C-s", []string{"ctrl+x", "ctrl+s"}},
+		{"]]", []string{"]", "]"}},
+		{"]u", []string{"]", "u"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.binding, func(t *testing.T) {
+			if got := parseKeySequence(tt.binding); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseKeySequence(%q) = %q, expected %q", tt.binding, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeySequences(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	config := DefaultConfig()
+	config.Keybindings.ScrollDown = append(config.Keybindings.ScrollDown, "M-n", "z")
+	config.Keybindings.ToggleDetails = []string{"z z"}
+	raw := strings.Repeat("Line.\n\n", 60)
+	m := newModel([]byte(raw), config)
+	m.width = 80
+	m.height = 10
+	m = m.refresh()
+
+	press := func(keys ...tea.KeyMsg) tea.Cmd {
+		var cmd tea.Cmd
+		for _, key := range keys {
+			var updated tea.Model
+			updated, cmd = m.handleKeyMsg(key)
+			m = updated.(model)
+		}
+		return cmd
+	}
+	runes := func(s string) []tea.KeyMsg {
+		var keys []tea.KeyMsg
+		for _, r := range s {
+			keys = append(keys, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+		return keys
+	}
+
+	press(runes("5j")...)
+	if m.yOffset != 5 {
+		t.Errorf("After 5j, yOffset = %d, expected 5", m.yOffset)
+	}
+
+	// A lone g waits for the rest of the sequence
+	press(runes("g")...)
+	if m.yOffset != 5 || !strings.Contains(m.renderStatusBar(), "g…") {
+		t.Errorf("Expected g to wait with a hint, got yOffset %d and status %q", m.yOffset, m.renderStatusBar())
+	}
+	press(runes("g")...)
+	if m.yOffset != 0 || m.pendingHint() != "" {
+		t.Errorf("After g g, yOffset = %d and hint %q, expected 0 and none", m.yOffset, m.pendingHint())
+	}
+
+	press(runes("12G")...)
+	if m.yOffset != 11 {
+		t.Errorf("After 12G, yOffset = %d, expected 11", m.yOffset)
+	}
+	press(runes("3")...)
+	if !strings.Contains(m.renderStatusBar(), "3…") {
+		t.Errorf("Expected the count in the status bar, got %q", m.renderStatusBar())
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	press(runes("k")...)
+	if m.yOffset != 10 {
+		t.Errorf("After dropping the count, k went to %d, expected 10", m.yOffset)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n"), Alt: true})
+	if m.yOffset != 11 {
+		t.Errorf("After M-n, yOffset = %d, expected 11", m.yOffset)
+	}
+
+	// A key bound alone and as the start of a sequence runs on timeout
+	cmd := press(runes("z")...)
+	if cmd == nil {
+		t.Fatal("Expected a timeout for z")
+	}
+	updated, _ := m.update(keyTimeoutMsg{id: m.pendingID - 1})
+	if updated.(model).pendingHint() != "z…" {
+		t.Errorf("Expected a stale timeout to be ignored")
+	}
+	updated, _ = m.update(keyTimeoutMsg{id: m.pendingID})
+	if got := updated.(model); got.pendingHint() != "" || got.yOffset != 12 {
+		t.Errorf("Expected z to scroll down on timeout, got hint %q and yOffset %d", got.pendingHint(), got.yOffset)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+)
+
+// minTextWidth keeps the text readable on very narrow terminals
+const minTextWidth = 20
+
+// textLayout is the position of the text column for a given terminal width
+type textLayout struct {
+	// margin is the number of columns left of the padding, for centering
+	margin int
+	// padding is the number of blank columns on each side of the text
+	padding int
+	// width is the width of the text itself
+	width int
+}
+
+// computeLayout places the text column in a terminal of termWidth columns
+func computeLayout(l LayoutConfig, termWidth int) textLayout {
+	layout := textLayout{padding: l.Padding}
+
+	layout.width = termWidth - 2*l.Padding
+	if l.MaxWidth > 0 && layout.width > l.MaxWidth {
+		layout.width = l.MaxWidth
+	}
+	layout.width = max(layout.width, minTextWidth)
+
+	if l.Align == "center" {
+		layout.margin = max((termWidth-layout.width-2*l.Padding)/2, 0)
+	}
+	return layout
+}
+
+// indent shifts every non-empty line of rendered by the layout margin
+func (l textLayout) indent(rendered []byte) []byte {
+	if l.margin == 0 {
+		return rendered
+	}
+
+	margin := strings.Repeat(" ", l.margin)
+	lines := strings.Split(string(rendered), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = margin + line
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeLayout(t *testing.T) {
+	tests := []struct {
+		name      string
+		layout    LayoutConfig
+		termWidth int
+		expected  textLayout
+	}{
+		{"narrow terminal", LayoutConfig{MaxWidth: 100, Padding: 4, Align: "center"}, 80, textLayout{margin: 0, padding: 4, width: 72}},
+		{"wide terminal centered", LayoutConfig{MaxWidth: 100, Padding: 4, Align: "center"}, 200, textLayout{margin: 46, padding: 4, width: 100}},
+		{"wide terminal left", LayoutConfig{MaxWidth: 100, Padding: 4, Align: "left"}, 200, textLayout{margin: 0, padding: 4, width: 100}},
+		{"no max width", LayoutConfig{MaxWidth: 0, Padding: 2, Align: "center"}, 200, textLayout{margin: 0, padding: 2, width: 196}},
+		{"no padding", LayoutConfig{MaxWidth: 60, Padding: 0, Align: "center"}, 100, textLayout{margin: 20, padding: 0, width: 60}},
+		{"tiny terminal", LayoutConfig{MaxWidth: 100, Padding: 4, Align: "center"}, 10, textLayout{margin: 0, padding: 4, width: minTextWidth}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeLayout(tt.layout, tt.termWidth); got != tt.expected {
+				t.Errorf("computeLayout() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseConfigLayout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	config, err := parseConfig([]byte(`{"layout": {"padding": 0}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DefaultLayout()
+	expected.Padding = 0
+	if config.Layout != expected {
+		t.Errorf("Expected an explicit zero padding to be kept, got %+v", config.Layout)
+	}
+
+	config, err = parseConfig([]byte(`{"layout": {"align": "right", "max_width": -1, "padding": -3, "tables": "shrink", "toc_width": 4}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Layout != (LayoutConfig{MaxWidth: 0, Padding: 4, Align: "center", Tables: tablesWrap, TOCWidth: 30}) {
+		t.Errorf("Expected invalid values to be replaced, got %+v", config.Layout)
+	}
+}
+
+func TestRenderCentersColumn(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	config := DefaultConfig()
+	config.Layout = LayoutConfig{MaxWidth: 40, Padding: 2, Align: "center"}
+	m := model{raw: "# Title\n\nSome text.\n", width: 100, config: config}
+
+	// (100 - 40 - 2*2) / 2 = 28 columns of margin, then 2 of padding
+	for _, line := range strings.Split(string(m.render()), "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, strings.Repeat(" ", 30)) || strings.HasPrefix(line, strings.Repeat(" ", 31)) {
+			t.Errorf("Expected 30 columns of indentation, got %q", line)
+		}
+	}
+}
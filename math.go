@@ -0,0 +1,582 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// TeX math is converted to Unicode text before rendering. Inline math
+// ($...$) is replaced in place, while display math ($$...$$) is replaced
+// with a placeholder paragraph like mermaid diagrams, and insertMath centers
+// the formulas in the text column once the document is rendered.
+//
+// go-term-markdown wraps lines between characters of different kinds, such
+// as a base and its scripts, so the words of inline formulas that mix them
+// are replaced with runs of private use characters as wide as the words,
+// holding their index among the formulas. insertInlineMath puts the words
+// back once the lines are wrapped.
+
+// mathMarker is a private use character that never appears in documents
+const mathMarker = "\uE002"
+
+// mathWordMarker fills the runs standing for the words of inline formulas
+const mathWordMarker = "\uE007"
+
+var (
+	mathPattern       = regexp.MustCompile(mathMarker + `(\d+)`)
+	mathWordPattern   = runPattern(mathWordMarker)
+	mathEscapePattern = regexp.MustCompile("[\\\\`*_\\[\\]<>#|~]")
+)
+
+// processMath converts the inline math of markdown and replaces display math
+// and the words of inline math with placeholders, returning the converted
+// formulas and words in document order
+func processMath(markdown string) (string, []string) {
+	lines := strings.Split(markdown, "\n")
+	var out, formulas []string
+	var fences codeFences
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fences.inCode(line) {
+			out = append(out, line)
+			continue
+		}
+
+		// Display math starts a line and may span several
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "$$") {
+			source, end := displayMath(lines, i)
+			if end >= 0 {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				out = append(out, "", indent+mathMarker+strconv.Itoa(len(formulas)), "")
+				formulas = append(formulas, texToUnicode(source))
+				i = end
+				continue
+			}
+		}
+
+		out = append(out, inlineMath(line, &formulas))
+	}
+	return strings.Join(out, "\n"), formulas
+}
+
+// displayMath returns the source of the display math opening on line start
+// and the line closing it, or -1 when it is not closed
+func displayMath(lines []string, start int) (string, int) {
+	first := strings.TrimPrefix(strings.TrimSpace(lines[start]), "$$")
+	if strings.HasSuffix(first, "$$") {
+		return strings.TrimSuffix(first, "$$"), start
+	}
+
+	source := []string{first}
+	for end := start + 1; end < len(lines); end++ {
+		line := strings.TrimSpace(lines[end])
+		if strings.HasSuffix(line, "$$") {
+			return strings.Join(append(source, strings.TrimSuffix(line, "$$")), " "), end
+		}
+		if line == "" {
+			break
+		}
+		source = append(source, line)
+	}
+	return "", -1
+}
+
+// inlineMath converts the $...$ math of line outside code spans. As in
+// Pandoc, the opening $ must be followed by a non-space and the closing one
+// preceded by a non-space and not followed by a digit, and math holds no
+// other $, so that prices are left alone. The words kept whole are added to
+// formulas.
+func inlineMath(line string, formulas *[]string) string {
+	return outsideCodeSpans(line, func(s string) string {
+		var sb strings.Builder
+		for i := 0; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				if i+1 < len(s) {
+					sb.WriteString(s[i : i+2])
+					i++
+					continue
+				}
+			case '$':
+				delimiter := "$"
+				if strings.HasPrefix(s[i:], "$$") {
+					delimiter = "$$"
+				}
+				if end := closingDollar(s, i+len(delimiter), delimiter); end >= 0 {
+					words := strings.Fields(texToUnicode(s[i+len(delimiter) : end]))
+					for j, word := range words {
+						words[j] = mathEscapePattern.ReplaceAllString(mathWord(word, formulas), `\$0`)
+					}
+					sb.WriteString(strings.Join(words, " "))
+					i = end + len(delimiter) - 1
+					continue
+				}
+			}
+			sb.WriteByte(s[i])
+		}
+		return sb.String()
+	})
+}
+
+// mathWord returns the run standing for word in the lines, adding word to
+// formulas, or word itself when it is wrapped like ordinary text or too
+// narrow for the run
+func mathWord(word string, formulas *[]string) string {
+	ascii := true
+	for _, r := range word {
+		ascii = ascii && r <= unicode.MaxASCII
+	}
+	if ascii {
+		return word
+	}
+	run, ok := placeholderRun(mathWordMarker, len(*formulas), runewidth.StringWidth(word))
+	if !ok {
+		return word
+	}
+	*formulas = append(*formulas, word)
+	return run
+}
+
+// insertInlineMath replaces the runs standing for words of inline formulas
+// in rendered with the words
+func insertInlineMath(rendered []byte, formulas []string) []byte {
+	return replaceRuns(rendered, mathWordPattern, formulas)
+}
+
+// closingDollar returns the index of the delimiter closing the math starting
+// at start in s, or -1
+func closingDollar(s string, start int, delimiter string) int {
+	if start >= len(s) || s[start] == ' ' {
+		return -1
+	}
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case strings.HasPrefix(s[i:], delimiter):
+			after := i + len(delimiter)
+			if s[i-1] == ' ' || (after < len(s) && s[after] >= '0' && s[after] <= '9') {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// insertMath replaces the placeholder lines of rendered with the display
+// formulas, centered in the column up to width and drawn in style
+func insertMath(rendered []byte, formulas []string, width int, style string) []byte {
+	return replacePlaceholders(rendered, mathPattern, func(n, indent int) []string {
+		if n >= len(formulas) {
+			return nil
+		}
+		var rows []string
+		for _, row := range strings.Split(formulas[n], "\n") {
+			column := indent + max(0, (width-indent-runewidth.StringWidth(row))/2)
+			if style != "" {
+				row = style + row + sgrReset
+			}
+			rows = append(rows, strings.Repeat(" ", column)+row)
+		}
+		return rows
+	})
+}
+
+// texToUnicode converts TeX math to Unicode text. Rows of display math
+// separated by \\ are returned on separate lines.
+func texToUnicode(source string) string {
+	p := &texParser{source: source}
+	var rows []string
+	for _, row := range strings.Split(p.parse(false), "\n") {
+		rows = append(rows, strings.Join(strings.Fields(row), " "))
+	}
+	return strings.Trim(strings.Join(rows, "\n"), "\n")
+}
+
+// texParser converts TeX math one token at a time
+type texParser struct {
+	source string
+	pos    int
+}
+
+// parse converts the source up to the end, or the closing brace of the
+// current group
+func (p *texParser) parse(group bool) string {
+	var sb strings.Builder
+	for p.pos < len(p.source) {
+		c := p.source[p.pos]
+		switch {
+		case c == '}' && group:
+			p.pos++
+			return sb.String()
+		case c == '^' || c == '_':
+			p.pos++
+			arg := p.argument()
+			if c == '^' {
+				sb.WriteString(scripted(arg, superscripts, "^"))
+			} else {
+				sb.WriteString(scripted(arg, subscripts, "_"))
+			}
+		case c == '&':
+			p.pos++
+			sb.WriteByte(' ')
+		case c == '~':
+			p.pos++
+			sb.WriteByte(' ')
+		default:
+			sb.WriteString(p.token())
+		}
+	}
+	return sb.String()
+}
+
+// token converts the next group, command or character
+func (p *texParser) token() string {
+	c := p.source[p.pos]
+	switch c {
+	case '{':
+		p.pos++
+		return p.parse(true)
+	case '\\':
+		return p.command()
+	case '\'':
+		p.pos++
+		return "′"
+	case '*':
+		p.pos++
+		return "∗"
+	case '-':
+		p.pos++
+		return "−"
+	}
+	r, size := utf8.DecodeRuneInString(p.source[p.pos:])
+	p.pos += size
+	return string(r)
+}
+
+// argument converts the argument of a command or script, a group or a
+// single token
+func (p *texParser) argument() string {
+	for p.pos < len(p.source) && p.source[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.source) {
+		return ""
+	}
+	return p.token()
+}
+
+// rawArgument returns the text of a group argument without converting it
+func (p *texParser) rawArgument() string {
+	for p.pos < len(p.source) && p.source[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos >= len(p.source) || p.source[p.pos] != '{' {
+		return p.argument()
+	}
+	depth := 0
+	for i := p.pos; i < len(p.source); i++ {
+		switch p.source[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := p.source[p.pos+1 : i]
+				p.pos = i + 1
+				return text
+			}
+		}
+	}
+	text := p.source[p.pos+1:]
+	p.pos = len(p.source)
+	return text
+}
+
+// optionalArgument returns the text of a [...] argument, if any
+func (p *texParser) optionalArgument() string {
+	if p.pos >= len(p.source) || p.source[p.pos] != '[' {
+		return ""
+	}
+	end := strings.IndexByte(p.source[p.pos:], ']')
+	if end < 0 {
+		return ""
+	}
+	text := p.source[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+	return text
+}
+
+// command converts the command at the current position
+func (p *texParser) command() string {
+	p.pos++
+	if p.pos >= len(p.source) {
+		return "\\"
+	}
+
+	// Commands are a run of letters, or a single other character
+	start := p.pos
+	for p.pos < len(p.source) && isASCIILetter(p.source[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++
+	}
+	name := p.source[start:p.pos]
+
+	if symbol, ok := texSymbols[name]; ok {
+		return symbol
+	}
+	if accent, ok := texAccents[name]; ok {
+		return accented(p.argument(), accent)
+	}
+	switch name {
+	case "\\":
+		return "\n"
+	case ",", ":", ";", " ", ">":
+		return " "
+	case "!":
+		return ""
+	case "quad":
+		return "  "
+	case "qquad":
+		return "    "
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.argument()
+		return fraction(num, p.argument())
+	case "binom", "dbinom", "tbinom":
+		n := p.argument()
+		return "C(" + n + ", " + p.argument() + ")"
+	case "sqrt":
+		index := p.optionalArgument()
+		return root(index, p.argument())
+	case "text", "textrm", "textit", "textbf", "mbox", "textsf", "texttt":
+		return p.rawArgument()
+	case "mathrm", "mathit", "mathbf", "mathsf", "mathtt", "boldsymbol", "mathcal", "mathscr", "mathfrak", "operatorname":
+		return p.argument()
+	case "mathbb":
+		return doubleStruck(p.argument())
+	case "left", "right", "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr":
+		// A "." delimiter is invisible
+		if p.pos < len(p.source) && p.source[p.pos] == '.' {
+			p.pos++
+		}
+		return ""
+	case "begin", "end":
+		p.rawArgument()
+		return ""
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return ""
+	}
+	if texOperators[name] {
+		return name
+	}
+	return "\\" + name
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// scripted writes s with the script characters of scripts, or after prefix
+// when some character has none
+func scripted(s string, scripts map[rune]rune, prefix string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		script, ok := scripts[r]
+		if !ok {
+			if len([]rune(s)) == 1 {
+				return prefix + s
+			}
+			return prefix + "(" + s + ")"
+		}
+		sb.WriteRune(script)
+	}
+	return sb.String()
+}
+
+// fraction writes num over den on one line
+func fraction(num, den string) string {
+	if f, ok := vulgarFractions[num+"/"+den]; ok {
+		return f
+	}
+	if isDigits(num) && isDigits(den) {
+		return scripted(num, superscripts, "") + "⁄" + scripted(den, subscripts, "")
+	}
+	return operand(num) + "/" + operand(den)
+}
+
+// root writes the root of x with the given index
+func root(index, x string) string {
+	sign := "√"
+	switch index {
+	case "":
+	case "3":
+		sign = "∛"
+	case "4":
+		sign = "∜"
+	default:
+		sign = scripted(index, superscripts, "") + "√"
+	}
+	if len([]rune(x)) > 1 && !isDigits(x) {
+		return sign + "(" + x + ")"
+	}
+	return sign + x
+}
+
+// operand puts s between parentheses unless it is a single term
+func operand(s string) string {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(".′", r) {
+			return "(" + s + ")"
+		}
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// accented puts the combining mark accent on every character of s
+func accented(s string, accent rune) string {
+	var sb strings.Builder
+	for _, r := range s {
+		sb.WriteRune(r)
+		if r != ' ' {
+			sb.WriteRune(accent)
+		}
+	}
+	return sb.String()
+}
+
+// doubleStruck writes the letters of s in double-struck style
+func doubleStruck(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("CHNPQRZ", r):
+			sb.WriteRune([]rune("ℂℍℕℙℚℝℤ")[strings.IndexRune("CHNPQRZ", r)])
+		case r >= 'A' && r <= 'Z':
+			sb.WriteRune(0x1D538 + r - 'A')
+		case r >= 'a' && r <= 'z':
+			sb.WriteRune(0x1D552 + r - 'a')
+		case r >= '0' && r <= '9':
+			sb.WriteRune(0x1D7D8 + r - '0')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+var texSymbols = map[string]string{
+	// Greek letters
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"omicron": "ο", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+
+	// Big operators
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬",
+	"iiint": "∭", "oint": "∮", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂",
+
+	// Binary operators and relations
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "•", "oplus": "⊕", "otimes": "⊗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+	"perp": "⊥", "parallel": "∥", "mid": "∣", "coloneqq": "≔",
+
+	// Arrows
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺",
+	"longrightarrow": "⟶", "longleftarrow": "⟵", "Longrightarrow": "⟹",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓", "updownarrow": "↕",
+	"Uparrow": "⇑", "Downarrow": "⇓", "hookrightarrow": "↪", "nearrow": "↗",
+	"searrow": "↘",
+
+	// Sets and logic
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆",
+	"supset": "⊃", "supseteq": "⊇", "subsetneq": "⊊", "cup": "∪", "cap": "∩",
+	"setminus": "∖", "emptyset": "∅", "varnothing": "∅", "forall": "∀",
+	"exists": "∃", "nexists": "∄", "neg": "¬", "lnot": "¬", "land": "∧",
+	"wedge": "∧", "lor": "∨", "vee": "∨", "top": "⊤", "bot": "⊥",
+	"vdash": "⊢", "models": "⊨",
+
+	// Miscellaneous symbols
+	"infty": "∞", "partial": "∂", "nabla": "∇", "prime": "′", "hbar": "ℏ",
+	"ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ", "angle": "∠",
+	"degree": "°", "triangle": "△", "square": "□", "checkmark": "✓",
+	"cdots": "⋯", "ldots": "…", "dots": "…", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "|": "‖", "Vert": "‖", "vert": "|",
+	"{": "{", "}": "}", "$": "$", "%": "%", "&": "&", "#": "#", "_": "_",
+}
+
+// texAccents holds the combining marks of accent commands
+var texAccents = map[string]rune{
+	"hat": '\u0302', "widehat": '\u0302', "bar": '\u0304', "overline": '\u0305',
+	"vec": '\u20D7', "dot": '\u0307', "ddot": '\u0308', "tilde": '\u0303',
+	"widetilde": '\u0303', "underline": '\u0332',
+}
+
+// texOperators are the function names written upright
+var texOperators = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true,
+	"tanh": true, "log": true, "ln": true, "lg": true, "exp": true, "lim": true,
+	"liminf": true, "limsup": true, "max": true, "min": true, "sup": true,
+	"inf": true, "det": true, "dim": true, "ker": true, "deg": true,
+	"gcd": true, "arg": true, "Pr": true,
+}
+
+var vulgarFractions = map[string]string{
+	"1/2": "½", "1/3": "⅓", "2/3": "⅔", "1/4": "¼", "3/4": "¾", "1/5": "⅕",
+	"2/5": "⅖", "3/5": "⅗", "4/5": "⅘", "1/6": "⅙", "5/6": "⅚", "1/8": "⅛",
+	"3/8": "⅜", "5/8": "⅝", "7/8": "⅞",
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶',
+	'7': '⁷', '8': '⁸', '9': '⁹', '+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼',
+	'(': '⁽', ')': '⁾', 'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ',
+	'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'i': 'ⁱ', 'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ',
+	'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ', 't': 'ᵗ',
+	'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ', 'A': 'ᴬ',
+	'B': 'ᴮ', 'D': 'ᴰ', 'E': 'ᴱ', 'G': 'ᴳ', 'H': 'ᴴ', 'I': 'ᴵ', 'J': 'ᴶ',
+	'K': 'ᴷ', 'L': 'ᴸ', 'M': 'ᴹ', 'N': 'ᴺ', 'O': 'ᴼ', 'P': 'ᴾ', 'R': 'ᴿ',
+	'T': 'ᵀ', 'U': 'ᵁ', 'V': 'ⱽ', 'W': 'ᵂ', 'α': 'ᵅ', 'β': 'ᵝ', 'γ': 'ᵞ',
+	'δ': 'ᵟ', 'θ': 'ᶿ', 'φ': 'ᵠ', 'χ': 'ᵡ', '′': '′', '∗': '*', ' ': ' ',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆',
+	'7': '₇', '8': '₈', '9': '₉', '+': '₊', '-': '₋', '−': '₋', '=': '₌',
+	'(': '₍', ')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ',
+	'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ', 'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ',
+	's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ', 'β': 'ᵦ', 'γ': 'ᵧ',
+	'ρ': 'ᵨ', 'φ': 'ᵩ', 'χ': 'ᵪ', ' ': ' ',
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestTexToUnicode(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{`\alpha + \beta^2 = \gamma_{i}`, "α + β² = γᵢ"},
+		{`\sum_{i=1}^{n} i = \frac{n(n+1)}{2}`, "∑ᵢ₌₁ⁿ i = (n(n+1))/2"},
+		{`\int_0^1 x\,dx = \frac{1}{2}`, "∫₀¹ x dx = ½"},
+		{`\frac{3}{16} + \frac{dy}{dx}`, "³⁄₁₆ + dy/dx"},
+		{`\sqrt{2} \cdot \sqrt[3]{x} \cdot \sqrt{x+1}`, "√2 ⋅ ∛x ⋅ √(x+1)"},
+		{`A \cup B \subseteq \mathbb{R}, \forall x \in A`, "A ∪ B ⊆ ℝ, ∀ x ∈ A"},
+		{`f \colon X \to Y \Rightarrow x \mapsto y`, `f \colon X → Y ⇒ x ↦ y`},
+		{`\left( \frac{a}{b} \right) \text{if } x_q \neq 0`, "( a/b ) if x_q ≠ 0"},
+		{`\sin x + \hat{x} + f'(x)`, "sin x + x̂ + f′(x)"},
+		{`\begin{aligned} a &= b \\ c &= d \end{aligned}`, "a = b\nc = d"},
+	}
+
+	for _, tt := range tests {
+		if got := texToUnicode(tt.source); got != tt.expected {
+			t.Errorf("texToUnicode(%q) = %q, expected %q", tt.source, got, tt.expected)
+		}
+	}
+}
+
+func TestInlineMath(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"Euler: $e^{i\\theta}$.", "Euler: eⁱᶿ."},
+		{"From $5 to $10 a day", "From $5 to $10 a day"},
+		{"Code `$x$` and \\$y$", "Code `$x$` and \\$y$"},
+		{"Not $a `b` c$ math", "Not $a `b` c$ math"},
+		{"Not $ x$ math", "Not $ x$ math"},
+		{"Product $a_i * b_i$", "Product aᵢ ∗ bᵢ"},
+		{"Escaped $x_q$", "Escaped x\\_q"},
+	}
+
+	for _, tt := range tests {
+		var formulas []string
+		got := string(insertInlineMath([]byte(inlineMath(tt.line, &formulas)), formulas))
+		if got != tt.expected {
+			t.Errorf("inlineMath(%q) = %q, expected %q", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestProcessMath(t *testing.T) {
+	markdown := "Text\n$$\n\\alpha \\\\\n\\beta\n$$\n```\n$$x$$\n```\n$$ unclosed\n"
+	got, formulas := processMath(markdown)
+
+	if len(formulas) != 1 || formulas[0] != "α\nβ" {
+		t.Fatalf("Expected formula %q, got %q", "α\nβ", formulas)
+	}
+	expected := "Text\n\n" + mathMarker + "0\n\n```\n$$x$$\n```\n$$ unclosed\n"
+	if got != expected {
+		t.Errorf("processMath() = %q, expected %q", got, expected)
+	}
+}
+
+func TestRenderDisplayMathCentered(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	config := DefaultConfig()
+	config.Layout = LayoutConfig{MaxWidth: 40, Padding: 2, Align: "left"}
+	m := model{raw: "Before\n\n$$E = mc^2$$\n\nAfter\n", width: 100, config: config}
+
+	for _, line := range strings.Split(string(m.render()), "\n") {
+		if !strings.Contains(line, "E = mc²") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if expected := 2 + (40-runewidth.StringWidth("E = mc²"))/2; indent != expected {
+			t.Errorf("Expected the formula at column %d, got %d: %q", expected, indent, line)
+		}
+		return
+	}
+	t.Error("Expected the formula in the rendered document")
+}
+
+func TestRenderInlineMathKeepsScripts(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	raw := "Some words here and $a^{10}_{ij}$ then $x_{n+1}^2 + y$ more words after it.\n"
+	for _, width := range []int{21, 24, 32} {
+		config := DefaultConfig()
+		config.Layout = LayoutConfig{MaxWidth: width, Padding: 2, Align: "left"}
+		m := model{raw: raw, width: 100, config: config}
+
+		rendered := stripANSI(string(m.render()))
+		for _, formula := range []string{"a¹⁰ᵢⱼ", "xₙ₊₁²"} {
+			if !strings.Contains(rendered, formula) {
+				t.Errorf("Expected %q on one line at width %d, got:\n%s", formula, width, rendered)
+			}
+		}
+		if strings.Contains(rendered, mathWordMarker) {
+			t.Errorf("Expected no placeholder left at width %d, got %q", width, rendered)
+		}
+	}
+}
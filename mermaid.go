@@ -0,0 +1,1408 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Mermaid code blocks are drawn as text diagrams. go-term-markdown would wrap
+// a diagram like any code block, so processMermaid replaces each block it can
+// draw with a placeholder paragraph, and insertDiagrams puts the drawings in
+// place of the placeholders once the document is rendered. Blocks using
+// diagram types or syntax outside the supported subset are left as code.
+
+// diagramMarker is a private use character that never appears in documents
+const diagramMarker = "\uE001"
+
+var (
+	mermaidFencePattern = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*mermaid\\s*$")
+	diagramPattern      = regexp.MustCompile(diagramMarker + `(\d+)`)
+	mermaidBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>`)
+)
+
+// processMermaid replaces the mermaid code blocks of markdown that can be
+// drawn with placeholders, and returns the drawings in document order
+func processMermaid(markdown string) (string, []string) {
+	lines := strings.Split(markdown, "\n")
+	var out, diagrams []string
+	var fences codeFences
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if match := mermaidFencePattern.FindStringSubmatch(line); match != nil && fences.open == "" {
+			if diagram, end, ok := drawMermaidBlock(lines, i, match[1]); ok {
+				out = append(out, "", diagramMarker+strconv.Itoa(len(diagrams)), "")
+				diagrams = append(diagrams, diagram)
+				i = end
+				continue
+			}
+		}
+		fences.inCode(line)
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n"), diagrams
+}
+
+// drawMermaidBlock draws the mermaid code block opened by the fence on line
+// start, and returns the drawing and the line of the closing fence
+func drawMermaidBlock(lines []string, start int, fence string) (string, int, bool) {
+	for end := start + 1; end < len(lines); end++ {
+		closing := strings.TrimSpace(lines[end])
+		if !strings.HasPrefix(closing, fence) || strings.Trim(closing, fence[:1]) != "" {
+			continue
+		}
+		diagram, err := drawMermaid(strings.Join(lines[start+1:end], "\n"))
+		return diagram, end, err == nil
+	}
+	return "", 0, false
+}
+
+// insertDiagrams replaces the placeholder lines of rendered with the
+// diagrams, drawn in style and indented like the placeholders
+func insertDiagrams(rendered []byte, diagrams []string, style string) []byte {
+	return replacePlaceholders(rendered, diagramPattern, func(n, indent int) []string {
+		if n >= len(diagrams) {
+			return nil
+		}
+		var rows []string
+		for _, row := range strings.Split(diagrams[n], "\n") {
+			if style != "" && row != "" {
+				row = style + row + sgrReset
+			}
+			rows = append(rows, strings.Repeat(" ", indent)+row)
+		}
+		return rows
+	})
+}
+
+// placeholderDigit is the first of the 256 private use characters writing
+// the index held by a placeholder run
+const placeholderDigit = '\uE100'
+
+// runPattern returns the pattern of the placeholder runs filled with marker
+func runPattern(marker string) *regexp.Regexp {
+	return regexp.MustCompile(marker + "([\uE100-\uE1FF]*)" + marker + "*")
+}
+
+// placeholderRun returns a run of private use characters width columns wide
+// standing for item n, the digits of n followed by marker. ok is false when
+// the run would be too narrow for them.
+func placeholderRun(marker string, n, width int) (run string, ok bool) {
+	var digits []rune
+	for ; ; n /= 256 {
+		digits = append(digits, placeholderDigit+rune(n%256))
+		if n < 256 {
+			break
+		}
+	}
+	if len(digits)+1 > width {
+		return "", false
+	}
+	return marker + string(digits) + strings.Repeat(marker, width-len(digits)-1), true
+}
+
+// replaceRuns replaces the placeholder runs matched by pattern in rendered
+// with the items they stand for. The pieces of a run broken across lines are
+// dropped.
+func replaceRuns(rendered []byte, pattern *regexp.Regexp, items []string) []byte {
+	if len(items) == 0 {
+		return rendered
+	}
+	return pattern.ReplaceAllFunc(rendered, func(run []byte) []byte {
+		n, scale := 0, 1
+		for _, r := range string(pattern.FindSubmatch(run)[1]) {
+			n += int(r-placeholderDigit) * scale
+			scale *= 256
+		}
+		if scale == 1 || n >= len(items) {
+			return nil
+		}
+		return []byte(items[n])
+	})
+}
+
+// replacePlaceholders replaces the rendered lines holding a placeholder
+// matched by pattern with the lines returned by block for the number of the
+// placeholder and its column. Lines are left as they are when block returns
+// nil.
+func replacePlaceholders(rendered []byte, pattern *regexp.Regexp, block func(n, indent int) []string) []byte {
+	lines := strings.Split(string(rendered), "\n")
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		plain := stripANSI(line)
+		match := pattern.FindStringSubmatchIndex(plain)
+		if match == nil {
+			out = append(out, line)
+			continue
+		}
+		n, err := strconv.Atoi(plain[match[2]:match[3]])
+		var rows []string
+		if err == nil {
+			rows = block(n, runewidth.StringWidth(plain[:match[0]]))
+		}
+		if rows == nil {
+			out = append(out, line)
+			continue
+		}
+		out = append(out, rows...)
+
+		// Paragraphs are not followed by a blank line before lists
+		if i+1 < len(lines) && strings.TrimSpace(stripANSI(lines[i+1])) != "" {
+			out = append(out, "")
+		}
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+// drawMermaid draws the mermaid diagram of source
+func drawMermaid(source string) (string, error) {
+	var statements []string
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		statements = append(statements, line)
+	}
+	if len(statements) == 0 {
+		return "", fmt.Errorf("empty diagram")
+	}
+
+	header := strings.Fields(statements[0])
+	switch header[0] {
+	case "flowchart", "graph":
+		direction := "TD"
+		if len(header) > 1 {
+			direction = strings.ToUpper(strings.TrimSuffix(header[1], ";"))
+		}
+		var body []string
+		for _, statement := range statements[1:] {
+			for _, s := range strings.Split(statement, ";") {
+				if s = strings.TrimSpace(s); s != "" {
+					body = append(body, s)
+				}
+			}
+		}
+		return drawFlowchart(body, direction)
+	case "sequenceDiagram":
+		return drawSequence(statements[1:])
+	}
+	return "", fmt.Errorf("unsupported diagram type %q", header[0])
+}
+
+// mermaidText cleans up the text of a node or message
+func mermaidText(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.TrimSpace(mermaidBreakPattern.ReplaceAllString(s, " "))
+}
+
+// canvas is a grid of cells to draw diagrams on. Cells hold either a rune or
+// a set of line directions and styles, which are joined into box-drawing
+// characters.
+type canvas struct {
+	cells [][]rune
+	lines [][]uint8
+}
+
+const (
+	lineUp uint8 = 1 << iota
+	lineDown
+	lineLeft
+	lineRight
+	// lineDotted and lineThick style the lines of a cell, thick winning
+	lineDotted
+	lineThick
+)
+
+// lineDirections masks the directions of a cell's lines
+const lineDirections = lineUp | lineDown | lineLeft | lineRight
+
+// wideFill marks the cell covered by the second column of a wide rune
+const wideFill = -1
+
+// lineRunes and thickLineRunes map the line directions of a cell to its
+// character
+var (
+	lineRunes = [16]rune{
+		' ', '│', '│', '│', '─', '┘', '┐', '┤',
+		'─', '└', '┌', '├', '─', '┴', '┬', '┼',
+	}
+	thickLineRunes = [16]rune{
+		' ', '┃', '┃', '┃', '━', '┛', '┓', '┫',
+		'━', '┗', '┏', '┣', '━', '┻', '┳', '╋',
+	}
+)
+
+// borderJoints holds the characters of box borders meeting a line
+var borderJoints = map[rune][4]rune{
+	// up, down, left, right
+	'─': {'┴', '┬', 0, 0},
+	'═': {'╧', '╤', 0, 0},
+	'│': {0, 0, '┤', '├'},
+	'║': {0, 0, '╢', '╟'},
+}
+
+func (c *canvas) grow(x, y int) {
+	for len(c.cells) <= y {
+		c.cells = append(c.cells, nil)
+		c.lines = append(c.lines, nil)
+	}
+	for len(c.cells[y]) <= x {
+		c.cells[y] = append(c.cells[y], 0)
+		c.lines[y] = append(c.lines[y], 0)
+	}
+}
+
+// set puts r in the cell at x, y
+func (c *canvas) set(x, y int, r rune) {
+	if x < 0 || y < 0 {
+		return
+	}
+	c.grow(x, y)
+	c.cells[y][x] = r
+}
+
+// text writes s from the cell at x, y
+func (c *canvas) text(x, y int, s string) {
+	for _, r := range s {
+		c.set(x, y, r)
+		if runewidth.RuneWidth(r) == 2 {
+			x++
+			c.set(x, y, wideFill)
+		}
+		x++
+	}
+}
+
+// join adds line directions to the cell at x, y
+func (c *canvas) join(x, y int, dirs uint8) {
+	if x < 0 || y < 0 {
+		return
+	}
+	c.grow(x, y)
+	c.lines[y][x] |= dirs
+}
+
+// line draws a horizontal or vertical line between two cells
+func (c *canvas) line(x1, y1, x2, y2 int) {
+	c.styledLine(x1, y1, x2, y2, 0)
+}
+
+// styledLine draws a line like line, dotted or thick as set by style
+func (c *canvas) styledLine(x1, y1, x2, y2 int, style uint8) {
+	if x1 == x2 {
+		if y1 > y2 {
+			y1, y2 = y2, y1
+		}
+		for y := y1; y <= y2; y++ {
+			if y > y1 {
+				c.join(x1, y, lineUp|style)
+			}
+			if y < y2 {
+				c.join(x1, y, lineDown|style)
+			}
+		}
+		return
+	}
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	for x := x1; x <= x2; x++ {
+		if x > x1 {
+			c.join(x, y1, lineLeft|style)
+		}
+		if x < x2 {
+			c.join(x, y1, lineRight|style)
+		}
+	}
+}
+
+// box draws a box of w by h cells at x, y with the corners and sides of
+// border, in the order top left, top right, bottom left, bottom right,
+// horizontal and vertical
+func (c *canvas) box(x, y, w, h int, border string) {
+	b := []rune(border)
+	for i := x + 1; i < x+w-1; i++ {
+		c.set(i, y, b[4])
+		c.set(i, y+h-1, b[4])
+	}
+	for j := y + 1; j < y+h-1; j++ {
+		c.set(x, j, b[5])
+		c.set(x+w-1, j, b[5])
+		for i := x + 1; i < x+w-1; i++ {
+			c.set(i, j, ' ')
+		}
+	}
+	c.set(x, y, b[0])
+	c.set(x+w-1, y, b[1])
+	c.set(x, y+h-1, b[2])
+	c.set(x+w-1, y+h-1, b[3])
+}
+
+// String returns the drawing, without trailing spaces
+func (c *canvas) String() string {
+	rows := make([]string, len(c.cells))
+	for y, cells := range c.cells {
+		var sb strings.Builder
+		for x, r := range cells {
+			dirs := c.lines[y][x]
+			switch {
+			case r == wideFill:
+			case r != 0:
+				sb.WriteRune(borderJoint(r, dirs))
+			default:
+				sb.WriteRune(lineRune(dirs))
+			}
+		}
+		rows[y] = strings.TrimRight(sb.String(), " ")
+	}
+	for len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+	return strings.Join(rows, "\n")
+}
+
+// lineRune returns the character of a cell holding lines. Dotted lines have
+// no corners, which are drawn plain.
+func lineRune(dirs uint8) rune {
+	shape := dirs & lineDirections
+	switch {
+	case dirs&lineThick != 0:
+		return thickLineRunes[shape]
+	case dirs&lineDotted != 0 && shape&(lineLeft|lineRight) == 0:
+		return '┆'
+	case dirs&lineDotted != 0 && shape&(lineUp|lineDown) == 0:
+		return '┄'
+	}
+	return lineRunes[shape]
+}
+
+// borderJoint returns the character of a box border r met by lines
+func borderJoint(r rune, dirs uint8) rune {
+	joints, ok := borderJoints[r]
+	if !ok {
+		return r
+	}
+	for i, dir := range []uint8{lineUp, lineDown, lineLeft, lineRight} {
+		if dirs&dir != 0 && joints[i] != 0 {
+			return joints[i]
+		}
+	}
+	return r
+}
+
+// Flowcharts are laid out in layers along the main axis, top to bottom for
+// TD and left to right for LR, with the nodes of a layer side by side along
+// the cross axis. Edges going back up are reversed to break cycles, and
+// edges spanning several layers go through dummy nodes in between, so that
+// every edge is drawn between neighbouring layers.
+
+const (
+	boxSquare   = "┌┐└┘─│"
+	boxRound    = "╭╮╰╯─│"
+	boxDecision = "╔╗╚╝═║"
+)
+
+var (
+	flowLabelPattern = regexp.MustCompile(`(^|\s)(--|==|-\.)\s+([^\s|>=.-][^|>]*?)\s+(-{2,}>|={2,}>|\.+-+>|-{3,}|={3,}|\.+-+)`)
+	flowEdgePattern  = regexp.MustCompile(`^<?(-{2,}>|={2,}>|-\.+-+>|-{3,}|={3,}|-\.+-+|-{2,}[ox]|={2,}[ox]|-\.+-+[ox])\s*(?:\|([^|]*)\|)?`)
+	flowNodePattern  = regexp.MustCompile(`^([^\s\[\](){}<>|&"]+)\s*(?:(\(\[|\[\[|\[\(|\(\(|\{\{|\[/|\[\\|\[|\(|\{|>)(.*?)(\]\)|\]\]|\)\]|\)\)|\}\}|/\]|\\\]|\]|\)|\}))?(?::::[\w-]+)?$`)
+	flowSkipPattern  = regexp.MustCompile(`^(subgraph|end|classDef|class|style|linkStyle|click|direction)\b`)
+)
+
+type flowNode struct {
+	text   string
+	border string
+	dummy  bool
+	layer  int
+	// main and cross are the position of the node on the axes
+	main, cross int
+	// ports is the number of ports on the busier side of the node
+	ports int
+}
+
+type flowEdge struct {
+	from, to int
+	label    string
+	arrow    bool
+	// style is lineDotted for -.-> edges, lineThick for ==> edges
+	style uint8
+}
+
+// flowSegment is the part of an edge between two neighbouring layers
+type flowSegment struct {
+	upper, lower int
+	label        string
+	style        uint8
+	// the arrow heads drawn at each end
+	arrowUp, arrowDown bool
+	// the positions of the ends on the cross axis, and the lane of the gap
+	// where the segment turns
+	fromPort, toPort, lane int
+	// the position of the label on the cross axis and its row in the gap,
+	// in top down flowcharts
+	labelCross, labelRow int
+}
+
+type flowchart struct {
+	horizontal bool
+	nodes      []flowNode
+	ids        map[string]int
+	edges      []flowEdge
+	segments   []flowSegment
+	layers     [][]int
+}
+
+// drawFlowchart draws the flowchart made of statements
+func drawFlowchart(statements []string, direction string) (string, error) {
+	f := &flowchart{ids: make(map[string]int)}
+	switch direction {
+	case "TD", "TB", "BT":
+	case "LR", "RL":
+		f.horizontal = true
+	default:
+		return "", fmt.Errorf("unsupported flowchart direction %q", direction)
+	}
+
+	for _, statement := range statements {
+		if flowSkipPattern.MatchString(statement) {
+			continue
+		}
+		if err := f.parse(statement); err != nil {
+			return "", err
+		}
+	}
+	if len(f.nodes) == 0 {
+		return "", fmt.Errorf("empty flowchart")
+	}
+	for _, e := range f.edges {
+		if e.from == e.to {
+			return "", fmt.Errorf("unsupported flowchart self-loop on %q", f.nodes[e.from].text)
+		}
+	}
+
+	f.assignLayers()
+	f.orderLayers()
+	return f.draw(), nil
+}
+
+// parse adds the nodes and edges of a statement like "A[Start] -->|go| B"
+func (f *flowchart) parse(statement string) error {
+	statement = flowLabelPattern.ReplaceAllStringFunc(statement, func(s string) string {
+		match := flowLabelPattern.FindStringSubmatch(s)
+		op := match[4]
+		if strings.HasPrefix(op, ".") {
+			op = "-" + op
+		}
+		return match[1] + op + "|" + match[3] + "|"
+	})
+
+	// Split the statement into node groups and the edges between them,
+	// skipping the text of nodes
+	var groups [][]int
+	var links []flowEdge
+	depth := 0
+	start := 0
+	for i := 0; i < len(statement); i++ {
+		switch statement[i] {
+		case '[', '(', '{':
+			depth++
+			continue
+		case ']', ')', '}':
+			depth--
+			continue
+		case '"':
+			if end := strings.IndexByte(statement[i+1:], '"'); end >= 0 {
+				i += end + 1
+			}
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		// Arrows may follow node ids without a space, as in A-->B
+		loc := flowEdgePattern.FindStringSubmatchIndex(statement[i:])
+		if loc == nil {
+			continue
+		}
+		group, err := f.parseNodes(statement[start:i])
+		if err != nil {
+			return err
+		}
+		op := statement[i+loc[2] : i+loc[3]]
+		link := flowEdge{arrow: !strings.HasSuffix(op, "-") && !strings.HasSuffix(op, "=")}
+		switch {
+		case strings.Contains(op, "."):
+			link.style = lineDotted
+		case strings.HasPrefix(op, "="):
+			link.style = lineThick
+		}
+		if loc[4] >= 0 {
+			link.label = mermaidText(statement[i+loc[4] : i+loc[5]])
+		}
+		groups = append(groups, group)
+		links = append(links, link)
+		i += loc[1] - 1
+		start = i + 1
+	}
+	group, err := f.parseNodes(statement[start:])
+	if err != nil {
+		return err
+	}
+	groups = append(groups, group)
+
+	for i, link := range links {
+		for _, from := range groups[i] {
+			for _, to := range groups[i+1] {
+				link.from, link.to = from, to
+				f.edges = append(f.edges, link)
+			}
+		}
+	}
+	return nil
+}
+
+// parseNodes adds the nodes of a group like "A & B[Text]" and returns their
+// indices
+func (f *flowchart) parseNodes(s string) ([]int, error) {
+	var nodes []int
+	for _, part := range splitOutsideBrackets(s, '&') {
+		// The > of flag shapes is closed by ], so that the end of an
+		// arrow isn't taken for one
+		match := flowNodePattern.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil || (match[2] == ">" && match[4] != "]") {
+			return nil, fmt.Errorf("unsupported flowchart node %q", strings.TrimSpace(part))
+		}
+		id := match[1]
+		n, ok := f.ids[id]
+		if !ok {
+			n = len(f.nodes)
+			f.ids[id] = n
+			f.nodes = append(f.nodes, flowNode{text: id, border: boxSquare})
+		}
+		if match[2] != "" {
+			f.nodes[n].text = mermaidText(match[3])
+			switch match[2] {
+			case "(", "([", "((":
+				f.nodes[n].border = boxRound
+			case "{", "{{":
+				f.nodes[n].border = boxDecision
+			default:
+				f.nodes[n].border = boxSquare
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// splitOutsideBrackets splits s around the sep bytes outside of brackets
+func splitOutsideBrackets(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// assignLayers puts every node in the layer after its lowest predecessor,
+// and splits the edges into segments between neighbouring layers
+func (f *flowchart) assignLayers() {
+	// Reverse the edges closing cycles, found by a depth-first search
+	outgoing := make([][]int, len(f.nodes))
+	for i, e := range f.edges {
+		outgoing[e.from] = append(outgoing[e.from], i)
+	}
+	reversed := make([]bool, len(f.edges))
+	state := make([]int, len(f.nodes))
+	var visit func(n int)
+	visit = func(n int) {
+		state[n] = 1
+		for _, i := range outgoing[n] {
+			switch state[f.edges[i].to] {
+			case 0:
+				visit(f.edges[i].to)
+			case 1:
+				reversed[i] = true
+			}
+		}
+		state[n] = 2
+	}
+	for n := range f.nodes {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+
+	// Longest path layering, in topological order
+	type arc struct{ upper, lower int }
+	var arcs []arc
+	incoming := make([]int, len(f.nodes))
+	below := make([][]int, len(f.nodes))
+	for i, e := range f.edges {
+		if e.from == e.to {
+			continue
+		}
+		a := arc{e.from, e.to}
+		if reversed[i] {
+			a = arc{e.to, e.from}
+		}
+		arcs = append(arcs, a)
+		incoming[a.lower]++
+		below[a.upper] = append(below[a.upper], a.lower)
+	}
+	var queue []int
+	for n := range f.nodes {
+		if incoming[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range below[n] {
+			f.nodes[m].layer = max(f.nodes[m].layer, f.nodes[n].layer+1)
+			if incoming[m]--; incoming[m] == 0 {
+				queue = append(queue, m)
+			}
+		}
+	}
+
+	count := 0
+	for _, n := range f.nodes {
+		count = max(count, n.layer+1)
+	}
+	f.layers = make([][]int, count)
+	for n := range f.nodes {
+		f.layers[f.nodes[n].layer] = append(f.layers[f.nodes[n].layer], n)
+	}
+
+	// Split the edges, adding dummy nodes in the layers they cross
+	for i, e := range f.edges {
+		if e.from == e.to {
+			continue
+		}
+		upper, lower := e.from, e.to
+		if reversed[i] {
+			upper, lower = e.to, e.from
+		}
+		first := true
+		for upper != lower {
+			next := lower
+			layer := f.nodes[upper].layer + 1
+			if layer < f.nodes[lower].layer {
+				next = len(f.nodes)
+				f.nodes = append(f.nodes, flowNode{dummy: true, layer: layer})
+				f.layers[layer] = append(f.layers[layer], next)
+			}
+			segment := flowSegment{upper: upper, lower: next, style: e.style}
+			if next == lower {
+				segment.label = e.label
+				segment.arrowDown = e.arrow && !reversed[i]
+			}
+			segment.arrowUp = first && e.arrow && reversed[i]
+			f.segments = append(f.segments, segment)
+			upper = next
+			first = false
+		}
+	}
+}
+
+// orderLayers sorts the nodes of each layer by the average position of their
+// predecessors, which avoids most crossings
+func (f *flowchart) orderLayers() {
+	position := make([]float64, len(f.nodes))
+	for pass := 0; pass < 2; pass++ {
+		for l, layer := range f.layers {
+			if l > 0 {
+				sum := make([]float64, len(f.nodes))
+				count := make([]int, len(f.nodes))
+				for _, s := range f.segments {
+					sum[s.lower] += position[s.upper]
+					count[s.lower]++
+				}
+				for _, n := range layer {
+					if count[n] > 0 {
+						position[n] = sum[n] / float64(count[n])
+					}
+				}
+				sort.SliceStable(layer, func(i, j int) bool {
+					return position[layer[i]] < position[layer[j]]
+				})
+			}
+			for i, n := range layer {
+				position[n] = float64(i)
+			}
+		}
+	}
+}
+
+// size returns the size of a node along the main and cross axes
+func (f *flowchart) size(n int) (int, int) {
+	node := f.nodes[n]
+	if node.dummy {
+		return 0, 1
+	}
+	width := runewidth.StringWidth(node.text) + 4
+	if f.horizontal {
+		return width, max(3, node.ports+2)
+	}
+	return 3, width
+}
+
+// center returns the middle of node n on the cross axis
+func (f *flowchart) center(n int) int {
+	_, cross := f.size(n)
+	return f.nodes[n].cross + cross/2
+}
+
+// point returns the canvas cell at the given positions on the axes
+func (f *flowchart) point(main, cross int) (int, int) {
+	if f.horizontal {
+		return main, cross
+	}
+	return cross, main
+}
+
+// placeNodes sets the position of the nodes on the cross axis. Nodes are
+// centered on their predecessors, and the first layer on its successors, as
+// far as the nodes before them in their layer allow.
+func (f *flowchart) placeNodes() {
+	gap := 3
+	if f.horizontal {
+		gap = 1
+	}
+	upper := make([][]int, len(f.nodes))
+	lower := make([][]int, len(f.nodes))
+	for _, s := range f.segments {
+		upper[s.lower] = append(upper[s.lower], s.upper)
+		lower[s.upper] = append(lower[s.upper], s.lower)
+	}
+
+	align := func(layer []int, neighbours [][]int) {
+		for i, n := range layer {
+			_, size := f.size(n)
+			cross := f.nodes[n].cross
+			if len(neighbours[n]) > 0 {
+				sum := 0
+				for _, m := range neighbours[n] {
+					sum += f.center(m)
+				}
+				cross = sum/len(neighbours[n]) - size/2
+			}
+			if i > 0 {
+				_, before := f.size(layer[i-1])
+				cross = max(cross, f.nodes[layer[i-1]].cross+before+gap)
+			}
+			f.nodes[n].cross = cross
+		}
+	}
+	for l, layer := range f.layers {
+		if l == 0 {
+			align(layer, make([][]int, len(f.nodes)))
+		} else {
+			align(layer, upper)
+		}
+	}
+	if len(f.layers) > 1 {
+		align(f.layers[0], lower)
+	}
+
+	least := f.nodes[0].cross
+	for _, node := range f.nodes {
+		least = min(least, node.cross)
+	}
+	for n := range f.nodes {
+		f.nodes[n].cross -= least
+	}
+}
+
+// portGroups returns the segments leaving each node and the segments
+// entering it, split into the groups sharing a port. Edges leaving a node
+// share a port and so do arrows entering it, as long as their lines look
+// alike, while back edges and labeled edges get their own.
+func (f *flowchart) portGroups() (outgoing, incoming [][][]int) {
+	group := func(groups [][]int, merged map[uint8]int, s int, shared bool) [][]int {
+		if !shared {
+			return append(groups, []int{s})
+		}
+		i, ok := merged[f.segments[s].style]
+		if !ok {
+			i = len(groups)
+			merged[f.segments[s].style] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], s)
+		return groups
+	}
+
+	outgoing = make([][][]int, len(f.nodes))
+	incoming = make([][][]int, len(f.nodes))
+	leaving := make([]map[uint8]int, len(f.nodes))
+	entering := make([]map[uint8]int, len(f.nodes))
+	for i, s := range f.segments {
+		if leaving[s.upper] == nil {
+			leaving[s.upper] = make(map[uint8]int)
+		}
+		if entering[s.lower] == nil {
+			entering[s.lower] = make(map[uint8]int)
+		}
+		outgoing[s.upper] = group(outgoing[s.upper], leaving[s.upper], i, !s.arrowUp)
+		incoming[s.lower] = group(incoming[s.lower], entering[s.lower], i, s.arrowDown && s.label == "")
+	}
+	return outgoing, incoming
+}
+
+// countPorts sets the number of ports of the nodes, which left to right
+// flowcharts make room for by drawing the nodes taller
+func (f *flowchart) countPorts() {
+	outgoing, incoming := f.portGroups()
+	for n := range f.nodes {
+		f.nodes[n].ports = max(len(outgoing[n]), len(incoming[n]))
+	}
+}
+
+// assignPorts sets where the segments meet the sides of the nodes, spreading
+// the ports along the side in the order of the nodes at their other end
+func (f *flowchart) assignPorts() {
+	outgoing, incoming := f.portGroups()
+	spread := func(n int, groups [][]int, other func(s flowSegment) int) map[int]int {
+		key := func(group []int) int {
+			sum := 0
+			for _, s := range group {
+				sum += f.center(other(f.segments[s]))
+			}
+			return sum / len(group)
+		}
+		sort.SliceStable(groups, func(i, j int) bool { return key(groups[i]) < key(groups[j]) })
+
+		_, size := f.size(n)
+		cross := f.nodes[n].cross
+		ports := make(map[int]int)
+		for i, group := range groups {
+			port := cross + size*(2*i+1)/(2*len(groups))
+			if size > 2 {
+				port = min(max(port, cross+1), cross+size-2)
+			}
+			for _, s := range group {
+				ports[s] = port
+			}
+		}
+		return ports
+	}
+	for n := range f.nodes {
+		ports := spread(n, outgoing[n], func(s flowSegment) int { return s.lower })
+		for s, port := range ports {
+			f.segments[s].fromPort = port
+		}
+		ports = spread(n, incoming[n], func(s flowSegment) int { return s.upper })
+		for s, port := range ports {
+			f.segments[s].toPort = port
+		}
+	}
+}
+
+// assignLanes gives the bending segments of a gap the lanes they turn in
+// and returns the number of lanes. Segments share a lane when they do not
+// overlap or have an end in common, and a segment turns above the segments
+// ending where it starts so that their vertical parts stay apart.
+func (f *flowchart) assignLanes(bending []int) int {
+	bounds := func(i int) (int, int) {
+		s := f.segments[i]
+		return min(s.fromPort, s.toPort), max(s.fromPort, s.toPort)
+	}
+	sort.SliceStable(bending, func(i, j int) bool {
+		a, _ := bounds(bending[i])
+		b, _ := bounds(bending[j])
+		return a < b
+	})
+	fits := func(i, j int) bool {
+		a, b := f.segments[i], f.segments[j]
+		if a.fromPort == b.fromPort || a.toPort == b.toPort {
+			return true
+		}
+		loA, hiA := bounds(i)
+		loB, hiB := bounds(j)
+		return hiA < loB-1 || hiB < loA-1
+	}
+
+	var lanes [][]int
+	assigned := make(map[int]bool)
+	for len(assigned) < len(bending) {
+		// The first segment with no unassigned segment to turn below
+		next := -1
+		for _, i := range bending {
+			if assigned[i] {
+				continue
+			}
+			if next < 0 {
+				next = i
+			}
+			ready := true
+			for _, j := range bending {
+				if !assigned[j] && j != i && f.segments[j].fromPort == f.segments[i].toPort {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		lane := 0
+		for _, j := range bending {
+			if assigned[j] && f.segments[j].fromPort == f.segments[next].toPort {
+				lane = max(lane, f.segments[j].lane+1)
+			}
+		}
+		for ; lane < len(lanes); lane++ {
+			free := true
+			for _, j := range lanes[lane] {
+				if !fits(next, j) {
+					free = false
+					break
+				}
+			}
+			if free {
+				break
+			}
+		}
+		if lane == len(lanes) {
+			lanes = append(lanes, nil)
+		}
+		lanes[lane] = append(lanes[lane], next)
+		f.segments[next].lane = lane
+		assigned[next] = true
+	}
+	return len(lanes)
+}
+
+// placeLabels sets where the labels of the segments of a gap go in top down
+// flowcharts, and returns the number of rows they take. Labels are centered
+// on their line, or put beside it when that would cover another line, and
+// go down a row when they would run into another label.
+func (f *flowchart) placeLabels(gap []int) int {
+	var labeled []int
+	for _, i := range gap {
+		if f.segments[i].label != "" {
+			labeled = append(labeled, i)
+		}
+	}
+	sort.SliceStable(labeled, func(i, j int) bool {
+		return f.segments[labeled[i]].toPort < f.segments[labeled[j]].toPort
+	})
+
+	var rows [][][2]int
+	for _, i := range labeled {
+		s := &f.segments[i]
+		width := runewidth.StringWidth(s.label)
+		cross := s.toPort - width/2
+		for _, j := range gap {
+			port := f.segments[j].toPort
+			if port == s.toPort || port < cross || port >= cross+width {
+				continue
+			}
+			if port > s.toPort {
+				cross = s.toPort - width
+			} else {
+				cross = s.toPort + 1
+			}
+			break
+		}
+
+		row := 0
+		for ; row < len(rows); row++ {
+			free := true
+			for _, span := range rows[row] {
+				if cross <= span[1] && span[0] <= cross+width {
+					free = false
+					break
+				}
+			}
+			if free {
+				break
+			}
+		}
+		if row == len(rows) {
+			rows = append(rows, nil)
+		}
+		rows[row] = append(rows[row], [2]int{cross, cross + width})
+		s.labelCross, s.labelRow = cross, row
+	}
+	return len(rows)
+}
+
+// draw places the nodes and draws the flowchart
+func (f *flowchart) draw() string {
+	f.countPorts()
+	f.placeNodes()
+	f.assignPorts()
+
+	// Main axis: each layer is followed by a gap holding the lanes where
+	// edges turn, the edge labels and the arrow heads
+	type gapLayout struct{ lanes, label, arrow int }
+	gaps := make([]gapLayout, len(f.layers))
+	ends := make([]int, len(f.layers))
+	main := 0
+	for l, layer := range f.layers {
+		size := 1
+		for _, n := range layer {
+			f.nodes[n].main = main
+			m, _ := f.size(n)
+			size = max(size, m)
+		}
+		ends[l] = main + size
+
+		lead, labels := 0, 0
+		if f.horizontal {
+			lead = 1
+		}
+		var gap, bending []int
+		for i, s := range f.segments {
+			if f.nodes[s.upper].layer != l {
+				continue
+			}
+			gap = append(gap, i)
+			if s.arrowUp {
+				lead = 1
+			}
+			if s.label != "" && f.horizontal {
+				labels = max(labels, runewidth.StringWidth(s.label)+2)
+			}
+			if s.fromPort != s.toPort {
+				bending = append(bending, i)
+			}
+		}
+		if !f.horizontal {
+			labels = f.placeLabels(gap)
+		}
+		g := gapLayout{lanes: ends[l] + lead}
+		g.label = g.lanes + max(1, f.assignLanes(bending))
+		g.arrow = g.label + labels
+		gaps[l] = g
+		main = g.arrow + 1
+	}
+
+	// Make room for the labels put beside the lines of the first nodes
+	least := 0
+	for _, s := range f.segments {
+		if s.label != "" && !f.horizontal {
+			least = min(least, s.labelCross)
+		}
+	}
+	for n := range f.nodes {
+		f.nodes[n].cross -= least
+	}
+	for i := range f.segments {
+		f.segments[i].fromPort -= least
+		f.segments[i].toPort -= least
+		f.segments[i].labelCross -= least
+	}
+
+	c := &canvas{}
+	arrows := [2]rune{'▲', '▼'}
+	if f.horizontal {
+		arrows = [2]rune{'◄', '►'}
+	}
+	for _, s := range f.segments {
+		g := gaps[f.nodes[s.upper].layer]
+		upper, lower := f.nodes[s.upper], f.nodes[s.lower]
+
+		start := ends[upper.layer] - 1
+		if !upper.dummy {
+			m, _ := f.size(s.upper)
+			start = upper.main + m - 1
+		}
+		if s.arrowUp {
+			start++
+			x, y := f.point(start, s.fromPort)
+			c.set(x, y, arrows[0])
+		}
+		end := lower.main
+		if s.arrowDown {
+			end--
+			x, y := f.point(end, s.toPort)
+			c.set(x, y, arrows[1])
+		}
+		lane := g.lanes + s.lane
+
+		points := [][2]int{{start, s.fromPort}, {lane, s.fromPort}, {lane, s.toPort}, {end, s.toPort}}
+		for i := 1; i < len(points); i++ {
+			x1, y1 := f.point(points[i-1][0], points[i-1][1])
+			x2, y2 := f.point(points[i][0], points[i][1])
+			c.styledLine(x1, y1, x2, y2, s.style)
+		}
+		if lower.dummy {
+			x1, y1 := f.point(lower.main, s.toPort)
+			x2, y2 := f.point(ends[lower.layer]-1, s.toPort)
+			c.styledLine(x1, y1, x2, y2, s.style)
+		}
+
+		if s.label != "" {
+			if f.horizontal {
+				c.text(g.label+1, s.toPort, s.label)
+			} else {
+				c.text(s.labelCross, g.label+s.labelRow, s.label)
+			}
+		}
+	}
+
+	for n, node := range f.nodes {
+		if node.dummy {
+			continue
+		}
+		w, h := f.point(f.size(n))
+		x, y := f.point(node.main, node.cross)
+		c.box(x, y, w, h, node.border)
+		c.text(x+2, y+(h-1)/2, node.text)
+	}
+	return c.String()
+}
+
+// Sequence diagrams put the participants side by side with a lifeline
+// below each, and the messages and notes one under the other.
+
+var (
+	seqParticipantPattern = regexp.MustCompile(`^(?:participant|actor)\s+(.+?)(?:\s+as\s+(.+))?$`)
+	seqMessagePattern     = regexp.MustCompile(`^([^\s:+-][^:]*?)\s*(--?)(>>|>|x|\))\s*[+-]?\s*([^\s:+-][^:]*?)\s*(?::\s*(.*))?$`)
+	seqNotePattern        = regexp.MustCompile(`(?i)^note\s+(left of|right of|over)\s+([^:]+?)\s*:\s*(.*)$`)
+	seqSkipPattern        = regexp.MustCompile(`^(loop|alt|else|opt|par|and|end|rect|critical|option|break|autonumber|activate|deactivate|title)\b`)
+)
+
+type seqEvent struct {
+	from, to int
+	text     string
+	dashed   bool
+	head     rune
+	// note is the placement of notes, empty for messages
+	note string
+}
+
+type sequence struct {
+	ids    map[string]int
+	labels []string
+	events []seqEvent
+}
+
+// participant returns the index of the participant id, adding it if needed
+func (s *sequence) participant(id string) int {
+	id = strings.TrimSpace(id)
+	if n, ok := s.ids[id]; ok {
+		return n
+	}
+	s.ids[id] = len(s.labels)
+	s.labels = append(s.labels, id)
+	return len(s.labels) - 1
+}
+
+// drawSequence draws the sequence diagram made of statements
+func drawSequence(statements []string) (string, error) {
+	s := &sequence{ids: make(map[string]int)}
+	for _, statement := range statements {
+		if match := seqParticipantPattern.FindStringSubmatch(statement); match != nil {
+			n := s.participant(match[1])
+			if match[2] != "" {
+				s.labels[n] = mermaidText(match[2])
+			}
+			continue
+		}
+		if match := seqNotePattern.FindStringSubmatch(statement); match != nil {
+			names := strings.Split(match[2], ",")
+			e := seqEvent{from: s.participant(names[0]), text: mermaidText(match[3]), note: strings.ToLower(match[1])}
+			e.to = e.from
+			if len(names) > 1 {
+				e.to = s.participant(names[1])
+			}
+			if e.from > e.to {
+				e.from, e.to = e.to, e.from
+			}
+			s.events = append(s.events, e)
+			continue
+		}
+		if seqSkipPattern.MatchString(statement) {
+			continue
+		}
+		if match := seqMessagePattern.FindStringSubmatch(statement); match != nil {
+			e := seqEvent{
+				from:   s.participant(match[1]),
+				to:     s.participant(match[4]),
+				text:   mermaidText(match[5]),
+				dashed: match[2] == "--",
+			}
+			switch match[3] {
+			case ">>", ")":
+				e.head = '►'
+			case "x":
+				e.head = '×'
+			}
+			s.events = append(s.events, e)
+			continue
+		}
+		return "", fmt.Errorf("unsupported sequence diagram statement %q", statement)
+	}
+	if len(s.labels) == 0 {
+		return "", fmt.Errorf("empty sequence diagram")
+	}
+	return s.draw(), nil
+}
+
+// draw places the participants and draws the sequence diagram
+func (s *sequence) draw() string {
+	// Lifelines are spaced for the participant boxes, then moved apart
+	// until the texts fit, the constraints spanning the fewest lifelines
+	// first. Index -1 stands for the left edge of the drawing.
+	widths := make([]int, len(s.labels))
+	xs := make([]int, len(s.labels))
+	for i, label := range s.labels {
+		widths[i] = runewidth.StringWidth(label) + 4
+		if i == 0 {
+			xs[i] = widths[i] / 2
+		} else {
+			xs[i] = xs[i-1] + widths[i-1] - widths[i-1]/2 + 2 + widths[i]/2
+		}
+	}
+	type spacing struct{ a, b, d int }
+	var spacings []spacing
+	for _, e := range s.events {
+		w := runewidth.StringWidth(e.text)
+		switch {
+		case e.note == "right of" && e.from+1 < len(xs):
+			spacings = append(spacings, spacing{e.from, e.from + 1, w + 7})
+		case e.note == "left of":
+			spacings = append(spacings, spacing{e.from - 1, e.from, w + 7})
+		case e.note == "over":
+			spacings = append(spacings, spacing{-1, e.from, (w + 4) / 2})
+			if e.from != e.to {
+				spacings = append(spacings, spacing{e.from, e.to, w - 1})
+			}
+		case e.note != "":
+		case e.from == e.to && e.from+1 < len(xs):
+			spacings = append(spacings, spacing{e.from, e.from + 1, w + 4})
+		case e.from != e.to:
+			spacings = append(spacings, spacing{min(e.from, e.to), max(e.from, e.to), w + 4})
+		}
+	}
+	sort.SliceStable(spacings, func(i, j int) bool {
+		return spacings[i].b-spacings[i].a < spacings[j].b-spacings[j].a
+	})
+	for _, sp := range spacings {
+		a := 0
+		if sp.a >= 0 {
+			a = xs[sp.a]
+		}
+		if deficit := sp.d - (xs[sp.b] - a); deficit > 0 {
+			for i := sp.b; i < len(xs); i++ {
+				xs[i] += deficit
+			}
+		}
+	}
+
+	// Notes over lifelines interrupt them
+	c := &canvas{}
+	covered := make([][]int, len(xs))
+	y := 4
+	for _, e := range s.events {
+		w := runewidth.StringWidth(e.text)
+		switch {
+		case e.note != "":
+			left := xs[e.from] - (w+4)/2
+			width := w + 4
+			switch e.note {
+			case "right of":
+				left = xs[e.from] + 2
+			case "left of":
+				left = xs[e.from] - 2 - width
+			default:
+				if e.from != e.to {
+					left = xs[e.from] - 2
+					width = max(width, xs[e.to]-xs[e.from]+5)
+				}
+				for i, x := range xs {
+					if x >= left && x < left+width {
+						covered[i] = append(covered[i], y)
+					}
+				}
+			}
+			c.box(left, y, width, 3, boxSquare)
+			c.text(left+(width-w)/2, y+1, e.text)
+			y += 3
+		case e.from == e.to:
+			x := xs[e.from]
+			c.text(x+2, y, e.text)
+			c.line(x, y+1, x+3, y+1)
+			c.line(x+3, y+1, x+3, y+2)
+			if e.head != 0 {
+				c.line(x+1, y+2, x+3, y+2)
+				c.set(x+1, y+2, '◄')
+			} else {
+				c.line(x, y+2, x+3, y+2)
+			}
+			y += 3
+		default:
+			from, to := xs[e.from], xs[e.to]
+			if e.text != "" {
+				c.text((from+to-w+1)/2, y, e.text)
+				y++
+			}
+			step := 1
+			if to < from {
+				step = -1
+			}
+			end := to
+			if e.head != 0 {
+				end -= step
+			}
+			c.line(from, y, end, y)
+			if e.dashed {
+				for x := from + step; x != end; x += step {
+					c.set(x, y, '╌')
+				}
+			}
+			switch {
+			case e.head == '►' && step < 0:
+				c.set(end, y, '◄')
+			case e.head != 0:
+				c.set(end, y, e.head)
+			}
+			y++
+		}
+	}
+
+	// Participant boxes at both ends of the lifelines
+	bottom := y + 1
+	for i, label := range s.labels {
+		left := xs[i] - widths[i]/2
+		top := 2
+		for _, note := range covered[i] {
+			c.line(xs[i], top, xs[i], note)
+			top = note + 2
+		}
+		c.line(xs[i], top, xs[i], bottom)
+		for _, row := range []int{0, bottom} {
+			c.box(left, row, widths[i], 3, boxSquare)
+			c.text(left+2, row+1, label)
+		}
+	}
+	return c.String()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDrawMermaid(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			name:   "flowchart top down",
+			source: "flowchart TD\n  A[Start] --> B(Work)\n  B -->|done| C{Check}",
+			expected: `
+┌───────┐
+│ Start │
+└───┬───┘
+    │
+    ▼
+╭──────╮
+│ Work │
+╰───┬──╯
+    │
+  done
+    ▼
+╔═══════╗
+║ Check ║
+╚═══════╝`,
+		},
+		{
+			name:   "flowchart left to right",
+			source: "graph LR\n  A -- yes --> B; A -.-> C",
+			expected: `
+              ┌───┐
+┌───┐ ┌──yes─►│ B │
+│ A ├─┘       └───┘
+│   ├┄┄┐
+└───┘  ┆      ┌───┐
+       └┄┄┄┄┄►│ C │
+              └───┘`,
+		},
+		{
+			name:   "opposite edges top down",
+			source: "graph TD\n  A -->|ping| B\n  B -->|pong| A",
+			expected: `
+ ┌───┐
+ │ A │
+ └┬──┘
+  │ ▲
+  │ │
+ping│pong
+  ▼ │
+ ┌──┴┐
+ │ B │
+ └───┘`,
+		},
+		{
+			name:   "opposite edges left to right",
+			source: "graph LR\n  A -->|ping| B\n  B -->|pong| A",
+			expected: `
+┌───┐         ┌───┐
+│ A ├───ping─►│ B │
+│   │◄──pong──┤   │
+└───┘         └───┘`,
+		},
+		{
+			name:   "dotted and thick edges",
+			source: "graph LR\n  A -. maybe .-> B\n  B == sure ==> C",
+			expected: `
+┌───┐          ┌───┐         ┌───┐
+│ A ├┄┄┄maybe┄►│ B ├━━━sure━►│ C │
+└───┘          └───┘         └───┘`,
+		},
+		{
+			name:   "sequence diagram",
+			source: "sequenceDiagram\n  Alice->>Bob: Hi\n  Bob-->>Alice: Hello",
+			expected: `
+┌───────┐  ┌─────┐
+│ Alice │  │ Bob │
+└───┬───┘  └──┬──┘
+    │         │
+    │   Hi    │
+    ├────────►│
+    │  Hello  │
+    │◄╌╌╌╌╌╌╌╌┤
+    │         │
+┌───┴───┐  ┌──┴──┐
+│ Alice │  │ Bob │
+└───────┘  └─────┘`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := drawMermaid(tt.source)
+			if err != nil {
+				t.Fatalf("drawMermaid() error: %v", err)
+			}
+			if expected := strings.TrimPrefix(tt.expected, "\n"); got != expected {
+				t.Errorf("drawMermaid() =\n%s\nexpected\n%s", got, expected)
+			}
+		})
+	}
+}
+
+func TestDrawMermaidCycle(t *testing.T) {
+	got, err := drawMermaid("flowchart TD\n  A --> B --> C --> A")
+	if err != nil {
+		t.Fatalf("drawMermaid() error: %v", err)
+	}
+	for _, want := range []string{"│ A │", "│ B │", "│ C │", "▲", "▼"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in drawing:\n%s", want, got)
+		}
+	}
+}
+
+func TestParseFlowchartWithoutSpaces(t *testing.T) {
+	tests := []struct {
+		statement string
+		nodes     []string
+		style     uint8
+	}{
+		{"A-->B", []string{"A", "B"}, 0},
+		{"A-->B[x]", []string{"A", "x"}, 0},
+		{"A-.->B", []string{"A", "B"}, lineDotted},
+		{"Start-->Stop", []string{"Start", "Stop"}, 0},
+		{"A[Go]==>B>Flag]", []string{"Go", "Flag"}, lineThick},
+	}
+	for _, tt := range tests {
+		t.Run(tt.statement, func(t *testing.T) {
+			f := &flowchart{ids: make(map[string]int)}
+			if err := f.parse(tt.statement); err != nil {
+				t.Fatalf("parse() error: %v", err)
+			}
+			var nodes []string
+			for _, n := range f.nodes {
+				nodes = append(nodes, n.text)
+			}
+			if !reflect.DeepEqual(nodes, tt.nodes) {
+				t.Errorf("parse() nodes = %q, expected %q", nodes, tt.nodes)
+			}
+			if len(f.edges) != 1 || f.edges[0].from != 0 || f.edges[0].to != 1 || f.edges[0].style != tt.style {
+				t.Errorf("parse() edges = %+v, expected one edge from 0 to 1 with style %d", f.edges, tt.style)
+			}
+		})
+	}
+}
+
+func TestDrawMermaidUnsupported(t *testing.T) {
+	sources := []string{
+		"pie title Pets\n  \"Dogs\" : 386",
+		"flowchart XY\n  A --> B",
+		"flowchart TD\n  A --> B --> B",
+		"sequenceDiagram\n  Alice says hi",
+		"",
+	}
+	for _, source := range sources {
+		if _, err := drawMermaid(source); err == nil {
+			t.Errorf("Expected an error for %q", source)
+		}
+	}
+}
+
+func TestProcessMermaid(t *testing.T) {
+	markdown := "Text\n```mermaid\ngraph LR\n  A --> B\n```\n\n```mermaid\npie\n```\n\n````\n```mermaid\ngraph LR\n```\n````\n"
+	got, diagrams := processMermaid(markdown)
+
+	if len(diagrams) != 1 {
+		t.Fatalf("Expected 1 diagram, got %d", len(diagrams))
+	}
+	expected := "Text\n\n" + diagramMarker + "0\n\n\n```mermaid\npie\n```\n\n````\n```mermaid\ngraph LR\n```\n````\n"
+	if got != expected {
+		t.Errorf("processMermaid() = %q, expected %q", got, expected)
+	}
+}
+
+func TestToggleDiagrams(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	raw := "Before\n\n```mermaid\ngraph LR\n  A[Client] --> B[Server]\n```\n\n- After\n"
+	m := model{raw: raw, width: 80, height: 20, config: DefaultConfig()}
+	m.search = NewSearchState(m.config)
+	m.renderedContent = m.render()
+
+	rendered := stripANSI(string(m.renderedContent))
+	if !strings.Contains(rendered, "│ Client ├──►│ Server │") {
+		t.Errorf("Expected the diagram to be drawn, got:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "└────────┘\n\n") {
+		t.Errorf("Expected a blank line after the diagram, got:\n%s", rendered)
+	}
+
+	updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = updated.(model)
+	rendered = stripANSI(string(m.renderedContent))
+	if !strings.Contains(rendered, "A[Client] --> B[Server]") || strings.Contains(rendered, "│ Client │") {
+		t.Errorf("Expected the source of the diagram, got:\n%s", rendered)
+	}
+}
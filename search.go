@@ -74,8 +74,9 @@ func stripANSIWithMapping(s string) (plainText string, posMap []int) {
 					j++ // Skip the final letter
 				}
 				i = j
-			} else if s[i+1] == ']' {
-				// Found OSC escape sequence (e.g., \x1b]8;;URL\x1b\\), skip it
+			} else if s[i+1] == ']' || s[i+1] == 'P' || s[i+1] == '_' {
+				// Found OSC escape sequence (e.g., \x1b]8;;URL\x1b\\), or the DCS
+				// and APC sequences images are drawn with, skip it
 				j := i + 2
 				// Skip until we find the string terminator \x1b\\ or BEL (\x07)
 				for j < len(s) {
@@ -89,6 +90,9 @@ func stripANSIWithMapping(s string) (plainText string, posMap []int) {
 					j++
 				}
 				i = j
+			} else if s[i+1] == '7' || s[i+1] == '8' {
+				// Found cursor save or restore, skip it
+				i += 2
 			} else {
 				plainBytes = append(plainBytes, s[i])
 				posMap = append(posMap, i)
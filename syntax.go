@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	md "github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// themeSyntaxStyle is the syntax_style deriving the highlighting palette
+// from the code block colors of the theme
+const themeSyntaxStyle = "theme"
+
+// go-term-markdown highlights every code block with
+// formatters.TTY8.Format(w, styles.Pygments, tokens) and doesn't tell which
+// language the block is in. installSyntaxHighlighter replaces that formatter
+// with one knowing the language of each block: the renderer formats the code
+// blocks in document order, so the n-th call is for the n-th block.
+
+// installSyntaxHighlighter points go-term-markdown's code block formatter at
+// the syntax styles of the config, for the code blocks of source
+func installSyntaxHighlighter(c *Config, source string) {
+	formatters.TTY8 = &syntaxFormatter{
+		config:    c,
+		languages: codeBlockLanguages(source),
+	}
+}
+
+// codeBlockLanguages returns the info string of every code block of source,
+// in document order
+func codeBlockLanguages(source string) []string {
+	var languages []string
+	for _, block := range codeBlocks(source) {
+		languages = append(languages, string(block.Info))
+	}
+	return languages
+}
+
+// codeBlocks returns the code blocks of source in document order, parsing it
+// the same way go-term-markdown does
+func codeBlocks(source string) []*ast.CodeBlock {
+	extensions := parser.NoIntraEmphasis | parser.Tables | parser.FencedCode |
+		parser.Autolink | parser.Strikethrough | parser.SpaceHeadings |
+		parser.HeadingIDs | parser.BackslashLineBreak | parser.DefinitionLists |
+		parser.LaxHTMLBlocks | parser.NoEmptyLineBeforeBlock
+
+	doc := md.Parse([]byte(source), parser.NewWithExtensions(extensions))
+
+	var blocks []*ast.CodeBlock
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if block, ok := node.(*ast.CodeBlock); ok && entering {
+			blocks = append(blocks, block)
+		}
+		return ast.GoToNext
+	})
+	return blocks
+}
+
+// syntaxFormatter is a chroma formatter picking the style of each code block
+// from the config and emitting colors in the active color profile
+type syntaxFormatter struct {
+	config    *Config
+	languages []string
+	block     int
+}
+
+func (f *syntaxFormatter) Format(w io.Writer, _ *chroma.Style, it chroma.Iterator) error {
+	language := ""
+	if f.block < len(f.languages) {
+		language = f.languages[f.block]
+	}
+	f.block++
+
+	style := f.config.SyntaxStyleFor(language)
+	for token := it(); token != chroma.EOF; token = it() {
+		seq := syntaxEntrySequence(&f.config.Colors, style.Get(token.Type))
+		if seq == "" {
+			fmt.Fprint(w, token.Value)
+			continue
+		}
+		// Keep line breaks outside of the escape sequences, the renderer
+		// wraps and trims the code line by line
+		for i, line := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				fmt.Fprint(w, "\n")
+			}
+			if strings.TrimSpace(line) != "" {
+				line = seq + line + sgrReset
+			}
+			fmt.Fprint(w, line)
+		}
+	}
+	return nil
+}
+
+// syntaxEntrySequence returns the escape sequence of a style entry. The
+// background is left out, code blocks use code_block_bg.
+func syntaxEntrySequence(c *ColorConfig, entry chroma.StyleEntry) string {
+	seq := ""
+	if entry.Bold == chroma.Yes {
+		seq += "\x1b[1m"
+	}
+	if entry.Italic == chroma.Yes {
+		seq += "\x1b[3m"
+	}
+	if entry.Underline == chroma.Yes {
+		seq += "\x1b[4m"
+	}
+	if entry.Colour.IsSet() {
+		seq += c.GetANSIColor(entry.Colour.String())
+	}
+	return seq
+}
+
+// SyntaxStyleFor returns the chroma style for a code block with the given
+// info string, honoring the per-language overrides
+func (c *Config) SyntaxStyleFor(info string) *chroma.Style {
+	name := c.SyntaxStyle
+	if language := strings.Fields(info); len(language) > 0 {
+		for key, override := range c.SyntaxStyles {
+			if sameLanguage(key, language[0]) {
+				name = override
+				break
+			}
+		}
+	}
+
+	if name == "" || strings.EqualFold(name, themeSyntaxStyle) {
+		return themePalette(&c.Colors)
+	}
+	if style, ok := styles.Registry[strings.ToLower(name)]; ok {
+		return style
+	}
+	return themePalette(&c.Colors)
+}
+
+// sameLanguage tells whether two language names designate the same lexer,
+// so that an override for "js" applies to "javascript" blocks
+func sameLanguage(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	la, lb := lexers.Get(a), lexers.Get(b)
+	return la != nil && lb != nil && la.Config().Name == lb.Config().Name
+}
+
+// validateSyntaxStyles reports syntax styles chroma doesn't know
+func (c *Config) validateSyntaxStyles() error {
+	names := []string{c.SyntaxStyle}
+	for _, name := range c.SyntaxStyles {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		if name == "" || strings.EqualFold(name, themeSyntaxStyle) {
+			continue
+		}
+		if _, ok := styles.Registry[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("unknown syntax style: %s (available: %s, %s)", name, themeSyntaxStyle, strings.Join(styles.Names(), ", "))
+		}
+	}
+	return nil
+}
+
+// themePalette derives a highlighting style from the code block colors:
+// code_block for the text, code for literals, and a blend of code_block and
+// code_block_bg for comments. Plain text is left unstyled, applyTheme gives
+// it the code_block color.
+func themePalette(c *ColorConfig) *chroma.Style {
+	text := c.CodeBlock
+	literal := c.Code
+	comment := c.CodeBlock
+	if fg, err := parseHexColor(c.CodeBlock); err == nil {
+		if bg, err := parseHexColor(c.CodeBlockBg); err == nil {
+			comment = fg.blend(bg, 0.45).hex()
+		}
+	}
+
+	style, err := chroma.NewStyle(themeSyntaxStyle, chroma.StyleEntries{
+		chroma.Comment:           "italic " + comment,
+		chroma.CommentPreproc:    "noitalic " + comment,
+		chroma.Keyword:           "bold " + text,
+		chroma.KeywordType:       "nobold " + literal,
+		chroma.NameBuiltin:       text,
+		chroma.NameFunction:      "bold " + text,
+		chroma.NameClass:         "bold " + text,
+		chroma.NameTag:           "bold " + text,
+		chroma.NameAttribute:     literal,
+		chroma.LiteralString:     literal,
+		chroma.LiteralNumber:     literal,
+		chroma.GenericHeading:    "bold " + text,
+		chroma.GenericSubheading: "bold " + text,
+		chroma.GenericDeleted:    comment,
+		chroma.GenericInserted:   literal,
+		chroma.GenericEmph:       "italic",
+		chroma.GenericStrong:     "bold",
+	})
+	if err != nil {
+		return styles.Fallback
+	}
+	return style
+}
+
+// blend mixes c with o, ratio being the share of o
+func (c rgbColor) blend(o rgbColor, ratio float64) rgbColor {
+	mix := func(a, b int) int {
+		return int(float64(a)*(1-ratio) + float64(b)*ratio + 0.5)
+	}
+	return rgbColor{mix(c.r, o.r), mix(c.g, o.g), mix(c.b, o.b)}
+}
+
+// hex formats the color as #rrggbb
+func (c rgbColor) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+}
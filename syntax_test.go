@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodeBlockLanguages(t *testing.T) {
+	source := "# Title\n\n```go\nfunc main() {}\n```\n\n- item\n\n  ```python\n  print(1)\n  ```\n\ntext\n\n```\nplain\n```\n"
+
+	got := codeBlockLanguages(source)
+	expected := []string{"go", "python", ""}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("codeBlockLanguages() = %q, expected %q", got, expected)
+	}
+}
+
+func TestSyntaxStyleFor(t *testing.T) {
+	config := DefaultConfig()
+	config.SyntaxStyle = "monokai"
+	config.SyntaxStyles = map[string]string{
+		"javascript": "github",
+		"python":     "theme",
+	}
+
+	tests := []struct {
+		info     string
+		expected string
+	}{
+		{"", "monokai"},
+		{"go", "monokai"},
+		{"javascript", "github"},
+		{"js", "github"},
+		{"JavaScript {.numberLines}", "github"},
+		{"python", themeSyntaxStyle},
+	}
+
+	for _, tt := range tests {
+		if got := config.SyntaxStyleFor(tt.info).Name; got != tt.expected {
+			t.Errorf("SyntaxStyleFor(%q) = %q, expected %q", tt.info, got, tt.expected)
+		}
+	}
+}
+
+func TestValidateSyntaxStyles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := parseConfig([]byte(`{"syntax_style": "monokai", "syntax_styles": {"go": "theme"}}`)); err != nil {
+		t.Errorf("Expected known styles to be accepted: %v", err)
+	}
+	if _, err := parseConfig([]byte(`{"syntax_style": "no-such-style"}`)); err == nil {
+		t.Error("Expected an error for an unknown syntax style")
+	}
+	if _, err := parseConfig([]byte(`{"syntax_styles": {"go": "no-such-style"}}`)); err == nil {
+		t.Error("Expected an error for an unknown per-language syntax style")
+	}
+}
+
+func TestSyntaxStylePerLanguage(t *testing.T) {
+	useColorProfile(t, ProfileTrueColor)
+
+	source := "```go\nfunc main() {}\n```\n\n```python\ndef main(): pass\n```\n"
+	config := DefaultConfig()
+	config.SyntaxStyles = map[string]string{"python": "monokai"}
+
+	m := model{raw: source, width: 80, config: config}
+	lines := strings.Split(string(m.render()), "\n")
+
+	// monokai keywords are #66d9ef, the theme palette makes them bold
+	monokaiKeyword := "\x1b[38;2;102;217;239m"
+	var goLine, pythonLine string
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, "func"):
+			goLine = line
+		case strings.Contains(line, "def"):
+			pythonLine = line
+		}
+	}
+
+	if strings.Contains(goLine, monokaiKeyword) {
+		t.Errorf("Expected the go block to use the theme palette, got %q", goLine)
+	}
+	if !strings.Contains(goLine, "\x1b[1m") {
+		t.Errorf("Expected bold keywords in the go block, got %q", goLine)
+	}
+	if !strings.Contains(pythonLine, monokaiKeyword) {
+		t.Errorf("Expected the python block to use monokai, got %q", pythonLine)
+	}
+}
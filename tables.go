@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MichaelMure/go-term-markdown"
+	"github.com/mattn/go-runewidth"
+)
+
+// go-term-markdown squeezes wide tables into the line and drops the columns
+// that don't fit, and it wraps links along with their URL. Tables are laid
+// out here instead: processTables replaces them with a placeholder paragraph,
+// renderTableCells renders their cells on their own, and insertTables draws
+// them in place of the placeholder once the paragraphs are wrapped.
+//
+// In the "wrap" layout, cells are wrapped to fit the text width. A table too
+// wide even so, and every wide table in the "scroll" layout, shows as many
+// columns as fit, with a scroll bar below it linking to "#table-<n>-left" and
+// "#table-<n>-right". The scroll keys and the links move that table alone.
+
+// Values of the layout.tables setting
+const (
+	tablesWrap   = "wrap"
+	tablesScroll = "scroll"
+)
+
+// tableMarker is a private use character that never appears in documents
+const tableMarker = "\uE005"
+
+const tableURL = "#table-"
+
+// minTableColumnWidth is the narrowest a column gets in the wrap layout
+const minTableColumnWidth = 3
+
+var (
+	tableDelimiterPattern = regexp.MustCompile(`^ {0,3}\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	tablePattern          = regexp.MustCompile(tableMarker + `(\d+)`)
+	tableCellPattern      = regexp.MustCompile(tableMarker + `(\d+) ?`)
+	tableScrollPattern    = regexp.MustCompile(`^` + tableURL + `(\d+)-(left|right)$`)
+	hyperlinkURLPattern   = regexp.MustCompile(`\x1b\]8;;([^\x1b]+)\x1b\\`)
+)
+
+// markdownTable is a table taken out of the document
+type markdownTable struct {
+	align []markdown.CellAlign
+	// rows holds the markdown of the cells, header first, then their
+	// rendering once renderTableCells ran
+	rows [][]string
+}
+
+// processTables replaces the tables of markdown with placeholders, outside
+// code blocks and quotes
+func processTables(markdown string) (string, []markdownTable) {
+	lines := strings.Split(markdown, "\n")
+	var out []string
+	var tables []markdownTable
+	var fences codeFences
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fences.inCode(line) {
+			out = append(out, line)
+			continue
+		}
+
+		table, ok := parseTableHead(line, lines[i+1:])
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+		i += 2
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+			row := splitTableRow(lines[i])
+			for len(row) < len(table.align) {
+				row = append(row, "")
+			}
+			table.rows = append(table.rows, row[:len(table.align)])
+		}
+		i--
+
+		out = append(out, "", tableMarker+strconv.Itoa(len(tables)), "")
+		tables = append(tables, table)
+	}
+	return strings.Join(out, "\n"), tables
+}
+
+// parseTableHead reads the header row of a table starting at line, followed
+// by the delimiter row in next
+func parseTableHead(line string, next []string) (markdownTable, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(next) == 0 || !strings.Contains(line, "|") || len(line)-len(trimmed) > 3 || strings.HasPrefix(trimmed, ">") {
+		return markdownTable{}, false
+	}
+	if !tableDelimiterPattern.MatchString(next[0]) {
+		return markdownTable{}, false
+	}
+	header := splitTableRow(line)
+	delimiters := splitTableRow(next[0])
+	if len(header) != len(delimiters) {
+		return markdownTable{}, false
+	}
+
+	table := markdownTable{rows: [][]string{header}}
+	for _, delimiter := range delimiters {
+		switch {
+		case strings.HasPrefix(delimiter, ":") && strings.HasSuffix(delimiter, ":"):
+			table.align = append(table.align, markdown.CellAlignCenter)
+		case strings.HasSuffix(delimiter, ":"):
+			table.align = append(table.align, markdown.CellAlignRight)
+		default:
+			table.align = append(table.align, markdown.CellAlignLeft)
+		}
+	}
+	return table, true
+}
+
+// splitTableRow returns the cells of a table row, split on the pipes that are
+// not escaped
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+
+	var cells []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, line[start:i])
+			start = i + 1
+		}
+	}
+	cells = append(cells, line[start:])
+	for i, cell := range cells {
+		cells[i] = strings.ReplaceAll(strings.TrimSpace(cell), `\|`, "|")
+	}
+	return cells
+}
+
+// renderTableCells renders the markdown of the cells of tables in a single
+// document, one paragraph per cell, and turns their links into hyperlinks
+func renderTableCells(tables []markdownTable, config *Config, hoveredURL string, opts []markdown.Options) {
+	var doc strings.Builder
+	n := 0
+	for _, table := range tables {
+		for _, row := range table.rows {
+			for _, cell := range row {
+				fmt.Fprintf(&doc, "%s%d %s\n\n", tableMarker, n, cell)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return
+	}
+
+	// Cells are not wrapped here, insertTables does it
+	rendered := markdown.Render(doc.String(), 1<<20, 0, opts...)
+	rendered = addHyperlinks(rendered, doc.String(), config, hoveredURL)
+
+	cells := make([]string, n)
+	for _, line := range strings.Split(string(rendered), "\n") {
+		match := tableCellPattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		if i, err := strconv.Atoi(line[match[2]:match[3]]); err == nil && i < n {
+			cells[i] = strings.TrimSpace(line[:match[0]] + line[match[1]:])
+		}
+	}
+
+	n = 0
+	for _, table := range tables {
+		for _, row := range table.rows {
+			for j := range row {
+				row[j] = cells[n]
+				n++
+			}
+		}
+	}
+}
+
+// insertTables draws the tables in place of their placeholders, width
+// columns wide at most. offsets holds the first column shown of the tables
+// that are scrolled.
+func insertTables(rendered []byte, tables []markdownTable, width int, mode string, offsets map[int]int, colors *ColorConfig) []byte {
+	if len(tables) == 0 {
+		return rendered
+	}
+	styles := newThemeStyles(colors)
+	return replacePlaceholders(rendered, tablePattern, func(n, indent int) []string {
+		if n >= len(tables) {
+			return nil
+		}
+		lines := drawTable(tables[n], n, max(width-indent, minTableColumnWidth+2), mode, offsets[n], styles)
+		pad := strings.Repeat(" ", indent)
+		for i := range lines {
+			lines[i] = pad + lines[i]
+		}
+		return lines
+	})
+}
+
+// tableColumnWidths returns the width of each column of a table with the
+// given natural widths, longest words and header widths, width columns wide
+// borders included. It returns nil when the columns can't be made to fit.
+//
+// Columns keep their natural width when the table fits. Otherwise each
+// column gets the width of its longest word, or of its header when there is
+// room for all of them, and the space left is shared in proportion to how
+// much wider the columns would like to be. When even the longest words
+// don't fit, the columns narrower than an even share of the line keep their
+// width and the others share the rest evenly.
+func tableColumnWidths(natural, words, headers []int, width int) []int {
+	available := width - len(natural) - 1
+	total := 0
+	for _, w := range natural {
+		total += w
+	}
+	if total <= available {
+		return append([]int(nil), natural...)
+	}
+	if available < len(natural)*minTableColumnWidth {
+		return nil
+	}
+
+	widths := make([]int, len(natural))
+	floor, wanted := 0, 0
+	for i := range natural {
+		widths[i] = min(max(max(words[i], headers[i]), minTableColumnWidth), natural[i])
+		floor += widths[i]
+	}
+	if floor > available {
+		floor = 0
+		for i := range natural {
+			widths[i] = min(max(words[i], minTableColumnWidth), natural[i])
+			floor += widths[i]
+		}
+	}
+	for i := range natural {
+		wanted += natural[i] - widths[i]
+	}
+
+	if floor <= available {
+		extra := available - floor
+		given := 0
+		for i := range widths {
+			share := extra * (natural[i] - widths[i]) / wanted
+			widths[i] += share
+			given += share
+		}
+		// The rounding leftovers go to the first columns that want them
+		for i := 0; given < extra && i < len(widths); i++ {
+			if widths[i] < natural[i] {
+				widths[i]++
+				given++
+			}
+		}
+		return widths
+	}
+
+	remaining := len(widths)
+	fair := available / len(widths)
+	for i, w := range widths {
+		if w <= fair {
+			available -= w
+			remaining--
+		} else {
+			widths[i] = -1
+		}
+	}
+	for i, w := range widths {
+		if w == -1 {
+			widths[i] = max(available/remaining, minTableColumnWidth)
+			available -= widths[i]
+			remaining--
+		}
+	}
+	return widths
+}
+
+// drawTable draws table number n, width columns wide at most, and returns
+// its lines. Cells are wrapped to fit in the wrap layout; otherwise the
+// columns from offset on that fit are shown, with a scroll bar below.
+func drawTable(table markdownTable, n, width int, mode string, offset int, styles themeStyles) []string {
+	columns := len(table.align)
+	natural := make([]int, columns)
+	words := make([]int, columns)
+	headers := make([]int, columns)
+	for r, row := range table.rows {
+		for i, cell := range row {
+			plain := stripANSI(cell)
+			if r == 0 {
+				headers[i] = runewidth.StringWidth(plain)
+			}
+			natural[i] = max(natural[i], runewidth.StringWidth(plain))
+			for _, word := range strings.Fields(plain) {
+				words[i] = max(words[i], runewidth.StringWidth(word))
+			}
+		}
+	}
+	for i := range natural {
+		natural[i] = max(natural[i], 1)
+	}
+
+	var widths []int
+	if mode == tablesWrap {
+		widths = tableColumnWidths(natural, words, headers, width)
+	}
+	first, last := 0, columns
+	if widths == nil {
+		// Columns are only wrapped when a single one is wider than the line
+		widths = make([]int, columns)
+		for i, w := range natural {
+			widths[i] = min(w, width-2)
+		}
+		first, last = tableWindow(widths, width, offset)
+	}
+
+	var lines []string
+	border := func(left, fill, cross, right string) {
+		var sb strings.Builder
+		sb.WriteString(left)
+		for i := first; i < last; i++ {
+			if i > first {
+				sb.WriteString(cross)
+			}
+			sb.WriteString(strings.Repeat(fill, widths[i]))
+		}
+		sb.WriteString(right)
+		lines = append(lines, styles.tableBorder+sb.String()+sgrReset)
+	}
+
+	border("┌", "─", "┬", "┐")
+	for r, row := range table.rows {
+		style := styles.tableRow
+		if r == 0 {
+			style = styles.tableHeader
+		} else if r > 1 {
+			border("├", "─", "┼", "┤")
+		}
+		for _, body := range drawTableRow(row[first:last], table.align[first:last], widths[first:last]) {
+			lines = append(lines, themeTableRow(body, style, styles))
+		}
+		if r == 0 {
+			border("╞", "═", "╪", "╡")
+		}
+	}
+	border("└", "─", "┴", "┘")
+
+	if first > 0 || last < columns {
+		lines = append(lines, tableScrollBar(n, first, last, columns, styles))
+	}
+	return lines
+}
+
+// tableWindow returns the range of columns shown from offset on, as many as
+// fit in width, moving offset back when there is room left for more
+func tableWindow(widths []int, width, offset int) (int, int) {
+	offset = max(0, min(offset, len(widths)-1))
+	used := 1
+	last := offset
+	for last < len(widths) && (last == offset || used+widths[last]+1 <= width) {
+		used += widths[last] + 1
+		last++
+	}
+	for offset > 0 && used+widths[offset-1]+1 <= width {
+		offset--
+		used += widths[offset] + 1
+	}
+	return offset, last
+}
+
+// drawTableRow returns the lines of a table row, each cell wrapped to the
+// width of its column and aligned in it
+func drawTableRow(cells []string, align []markdown.CellAlign, widths []int) []string {
+	wrapped := make([][]string, len(cells))
+	height := 1
+	for i, cell := range cells {
+		if runewidth.StringWidth(stripANSI(cell)) > widths[i] {
+			cell = wrapLine(cell, lineWrap{prose: true}, widths[i])
+		}
+		wrapped[i] = strings.Split(cell, "\n")
+		height = max(height, len(wrapped[i]))
+	}
+
+	lines := make([]string, height)
+	for l := range lines {
+		var sb strings.Builder
+		sb.WriteString("│")
+		for i, cellLines := range wrapped {
+			content := ""
+			if l < len(cellLines) {
+				content = strings.TrimRight(cellLines[l], " ")
+			}
+			space := max(widths[i]-runewidth.StringWidth(stripANSI(content)), 0)
+			left := 0
+			switch align[i] {
+			case markdown.CellAlignRight:
+				left = space
+			case markdown.CellAlignCenter:
+				left = space / 2
+			}
+			sb.WriteString(strings.Repeat(" ", left) + content + strings.Repeat(" ", space-left))
+			sb.WriteString("│")
+		}
+		lines[l] = sb.String()
+	}
+	return lines
+}
+
+// tableScrollBar returns the line below a scrolled table telling which
+// columns are shown, with links to scroll it where there are more columns
+func tableScrollBar(n, first, last, columns int, styles themeStyles) string {
+	arrow := func(symbol, direction string, active bool) string {
+		if !active {
+			return symbol
+		}
+		return fmt.Sprintf("\x1b]8;;%s%d-%s\x1b\\%s\x1b]8;;\x1b\\", tableURL, n, direction, symbol)
+	}
+	shown := fmt.Sprintf("%d-%d", first+1, last)
+	if last == first+1 {
+		shown = strconv.Itoa(last)
+	}
+	return styles.tableBorder + arrow("◀", "left", first > 0) +
+		fmt.Sprintf(" columns %s of %d ", shown, columns) +
+		arrow("▶", "right", last < columns) + sgrReset
+}
+
+// isTableURL tells whether url is the link of a table scroll bar
+func isTableURL(url string) bool {
+	return tableScrollPattern.MatchString(url)
+}
+
+// scrollTable shows the columns of a table in the direction of a scroll bar
+// link
+func (m model) scrollTable(url string) model {
+	match := tableScrollPattern.FindStringSubmatch(url)
+	if match == nil {
+		return m
+	}
+	n, _ := strconv.Atoi(match[1])
+	if m.tableOffsets == nil {
+		m.tableOffsets = make(map[int]int)
+	}
+	if match[2] == "left" {
+		m.tableOffsets[n] = max(m.tableOffsets[n]-1, 0)
+	} else {
+		m.tableOffsets[n]++
+	}
+	return m.refresh()
+}
+
+// visibleTableScroll returns the scroll bar link moving the first scrolled
+// table on screen in direction, or an empty string when it can't move that
+// way. ok is false when no scrolled table is on screen.
+func (m model) visibleTableScroll(direction string) (url string, ok bool) {
+	lines := strings.Split(string(m.renderedContent), "\n")
+	top := -1
+	for i, line := range lines {
+		plain := strings.TrimLeft(stripANSI(line), " ")
+		if strings.HasPrefix(plain, "┌") {
+			top = i
+		}
+		start := strings.Index(line, "\x1b]8;;"+tableURL)
+		if start < 0 {
+			continue
+		}
+		if i < m.yOffset || top >= m.yOffset+m.height {
+			continue
+		}
+
+		// The scroll bar holds the links of both directions the table can move
+		for _, match := range hyperlinkURLPattern.FindAllStringSubmatch(line, -1) {
+			if strings.HasSuffix(match[1], "-"+direction) {
+				return match[1], true
+			}
+		}
+		return "", true
+	}
+	return "", false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MichaelMure/go-term-markdown"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+func TestProcessTables(t *testing.T) {
+	input := "Text\n| A | B \\| C | D |\n|:--|:-:|--:|\n| 1 | `x` |\n| 2 | 3 | 4 | 5 |\nAfter\n\n```\n| a | b |\n|---|---|\n```\n> | a | b |\n> |---|---|"
+	got, tables := processTables(input)
+	expected := "Text\n\n" + tableMarker + "0\n\nAfter\n\n```\n| a | b |\n|---|---|\n```\n> | a | b |\n> |---|---|"
+	if got != expected {
+		t.Errorf("processTables() = %q, expected %q", got, expected)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("processTables() found %d tables, expected 1", len(tables))
+	}
+
+	align := []markdown.CellAlign{markdown.CellAlignLeft, markdown.CellAlignCenter, markdown.CellAlignRight}
+	if !reflect.DeepEqual(tables[0].align, align) {
+		t.Errorf("Alignments = %v, expected %v", tables[0].align, align)
+	}
+	rows := [][]string{{"A", "B | C", "D"}, {"1", "`x`", ""}, {"2", "3", "4"}}
+	if !reflect.DeepEqual(tables[0].rows, rows) {
+		t.Errorf("Rows = %q, expected %q", tables[0].rows, rows)
+	}
+}
+
+func TestTableColumnWidths(t *testing.T) {
+	tests := []struct {
+		name     string
+		natural  []int
+		words    []int
+		headers  []int
+		width    int
+		expected []int
+	}{
+		{"fits", []int{4, 10}, []int{4, 5}, []int{4, 4}, 20, []int{4, 10}},
+		{"shared in proportion", []int{10, 40}, []int{5, 8}, []int{4, 4}, 33, []int{8, 22}},
+		{"narrow header beside wide column", []int{5, 40}, []int{3, 8}, []int{5, 11}, 30, []int{5, 22}},
+		{"headers too wide", []int{12, 40}, []int{3, 8}, []int{12, 20}, 21, []int{5, 13}},
+		{"longest words too wide", []int{30, 20, 4}, []int{30, 20, 4}, []int{4, 4, 4}, 34, []int{13, 13, 4}},
+		{"too many columns", []int{8, 8, 8, 8}, []int{8, 8, 8, 8}, []int{4, 4, 4, 4}, 16, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tableColumnWidths(tt.natural, tt.words, tt.headers, tt.width); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("tableColumnWidths() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderWrappedTable(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	raw := "| Name | Description | Home |\n|---|---|---|\n| bleamd | A terminal markdown viewer with themes and search | [site](https://example.com) |\n"
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 48
+	m = m.refresh()
+
+	var lines []string
+	for _, line := range strings.Split(string(m.renderedContent), "\n") {
+		plain := stripANSI(line)
+		if width := runewidth.StringWidth(plain); width > 44 {
+			t.Errorf("Line wider than the text: %q (%d columns)", plain, width)
+		}
+		lines = append(lines, plain)
+	}
+	rendered := strings.Join(lines, "\n")
+	if !strings.Contains(rendered, "│bleamd│A terminal markdown viewer│site│\n    │      │with themes and search    │    │") {
+		t.Errorf("Expected the description to be wrapped, got:\n%s", rendered)
+	}
+	if !strings.Contains(string(m.renderedContent), "\x1b]8;;https://example.com\x1b\\site") {
+		t.Errorf("Expected the link to stay a hyperlink, got %q", m.renderedContent)
+	}
+}
+
+func TestScrollTable(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	config := DefaultConfig()
+	config.Layout.Tables = tablesScroll
+	raw := "Intro.\n\n| One | Two | Three | Four | Five | Six |\n|---|---|---|---|---|---|\n| first | second | third | fourth | fifth | sixth |\n"
+	m := newModel([]byte(raw), config)
+	m.width = 40
+	m.height = 20
+	m = m.refresh()
+
+	rendered := stripANSI(string(m.renderedContent))
+	if !strings.Contains(rendered, "│One  │Two   │Three│Four  │") || !strings.Contains(rendered, "columns 1-4 of 6") {
+		t.Fatalf("Expected the first columns and a scroll bar, got:\n%s", rendered)
+	}
+
+	// The scroll keys move the table, not the document
+	for i := 0; i < 2; i++ {
+		updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+		m = updated.(model)
+	}
+	rendered = stripANSI(string(m.renderedContent))
+	if m.xOffset != 0 || !strings.Contains(rendered, "columns 3-6 of 6") || strings.Contains(rendered, "│Two") {
+		t.Fatalf("Expected the table to scroll right, got xOffset %d and:\n%s", m.xOffset, rendered)
+	}
+
+	// The table can't scroll further right
+	updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updated.(model)
+	if m.xOffset != 0 || !strings.Contains(stripANSI(string(m.renderedContent)), "columns 3-6 of 6") {
+		t.Errorf("Expected the table to stay put, got xOffset %d", m.xOffset)
+	}
+
+	// Clicking the left arrow scrolls back
+	var left linkPosition
+	for _, link := range m.linkPositions {
+		if link.url == tableURL+"0-left" {
+			left = link
+		}
+	}
+	if left.url == "" {
+		t.Fatal("Expected the left arrow to be on screen")
+	}
+	updated, _ = m.handleMouseMsg(tea.MouseMsg{X: left.x, Y: left.y, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+	m = updated.(model)
+	if !strings.Contains(stripANSI(string(m.renderedContent)), "columns 2-5 of 6") {
+		t.Errorf("Expected the table to scroll back left, got:\n%s", stripANSI(string(m.renderedContent)))
+	}
+
+	// Without a scrolled table on screen, the document scrolls
+	m.yOffset = m.lines
+	updated, _ = m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	if updated.(model).xOffset != 1 {
+		t.Errorf("Expected the document to scroll, got xOffset %d", updated.(model).xOffset)
+	}
+}
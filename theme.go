@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MichaelMure/go-term-markdown"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// go-term-markdown only knows a fixed green/blue palette. Theming happens in
+// two steps:
+//   1. installRendererPalette swaps the renderer's exported color functions
+//      (list markers, inline code, link destinations, code block gutter) for
+//      theme-aware ones whenever a theme is picked. go-term-markdown has no
+//      color options, so these are package globals.
+//   2. applyTheme post-processes the rendered output and restyles everything
+//      the renderer hard-codes: headings, emphasis, quotes, alerts, code
+//      blocks and tables.
+
+// codeBlockGutterOpen marks the gutter of a code block. Quotes use the same
+// "┃ " bar, so the gutter carries this (visually inert) SGR sequence to tell
+// them apart. applyTheme always replaces it.
+const codeBlockGutterOpen = "\x1b[0;0m"
+
+const sgrReset = "\x1b[0m"
+
+var (
+	sgrPattern         = regexp.MustCompile(`\x1b\[([0-9;]*)m`)
+	headingPattern     = regexp.MustCompile(`^\x1b\[(?:32;1|92|32)m(\d+(?:\.\d+)*) `)
+	headingContPattern = regexp.MustCompile(`^\x1b\[(?:1;32|32;1|92|32)m`)
+	quoteBarPattern    = regexp.MustCompile(`^(?:\x1b\[(?:32;1|92|32)m)?┃ (?:\x1b\[0m)?`)
+	taskPattern        = regexp.MustCompile(`(• (?:\x1b\[0m)?)\[([ xX])\]`)
+	ruleLinePattern    = regexp.MustCompile(`^─+$`)
+)
+
+// installRendererPalette points go-term-markdown's color functions at the
+// colors of the active theme
+func installRendererPalette(c *ColorConfig) {
+	markdown.Green = paletteFunc(c.GetANSIColor(c.ListMarker))
+	markdown.Blue = paletteFunc(c.GetANSIColor(c.LinkURL))
+	markdown.BlueBgItalic = paletteFunc(c.GetANSIColor(c.Code) + c.GetANSIBackground(c.CodeBlockBg))
+	markdown.GreenBold = paletteFunc(codeBlockGutterOpen)
+}
+
+// paletteFunc wraps its arguments in the given escape sequence
+func paletteFunc(seq string) func(a ...interface{}) string {
+	return func(a ...interface{}) string {
+		s := fmt.Sprint(a...)
+		if seq == "" {
+			return s
+		}
+		return seq + s + sgrReset
+	}
+}
+
+// themeStyles holds the escape sequences derived from a ColorConfig
+type themeStyles struct {
+	headings      [6]string
+	bold          string
+	italic        string
+	strikethrough string
+	codeBlock     string
+	codeGutter    string
+	blockQuote    string
+	taskChecked   string
+	taskUnchecked string
+	tableHeader   string
+	tableRow      string
+	tableBorder   string
+	alerts        []string
+}
+
+func newThemeStyles(c *ColorConfig) themeStyles {
+	headings := []string{c.Heading1, c.Heading2, c.Heading3, c.Heading4, c.Heading5, c.Heading6}
+
+	var s themeStyles
+	for i, hex := range headings {
+		s.headings[i] = "\x1b[1m" + c.GetANSIColor(hex)
+	}
+	s.bold = c.GetANSIColor(c.Bold)
+	s.italic = c.GetANSIColor(c.Italic)
+	s.strikethrough = c.GetANSIColor(c.Strikethrough)
+	s.codeBlock = c.GetANSIColor(c.CodeBlock) + c.GetANSIBackground(c.CodeBlockBg)
+	s.codeGutter = c.GetANSIColor(c.CodeBlock)
+	s.blockQuote = c.GetANSIColor(c.BlockQuote)
+	s.taskChecked = c.GetANSIColor(c.TaskChecked)
+	s.taskUnchecked = c.GetANSIColor(c.TaskUnchecked)
+	s.tableHeader = "\x1b[1m" + c.GetANSIColor(c.TableHeader)
+	s.tableRow = c.GetANSIColor(c.TableRow)
+	s.tableBorder = c.GetANSIColor(c.TableBorder)
+	for _, alert := range alertTypes {
+		s.alerts = append(s.alerts, c.GetANSIColor(alert.color(c)))
+	}
+	return s
+}
+
+// themedLine is a rendered line split into its structural prefix and body
+type themedLine struct {
+	indent     string
+	quoteDepth int
+	code       bool
+	body       string
+}
+
+func parseThemedLine(line string) themedLine {
+	var l themedLine
+	rest := strings.TrimLeft(line, " ")
+	l.indent = line[:len(line)-len(rest)]
+
+	for {
+		loc := quoteBarPattern.FindStringIndex(rest)
+		if loc == nil {
+			break
+		}
+		l.quoteDepth++
+		rest = rest[loc[1]:]
+	}
+
+	if strings.HasPrefix(rest, codeBlockGutterOpen+"┃ "+sgrReset) {
+		l.code = true
+		rest = strings.TrimPrefix(rest, codeBlockGutterOpen+"┃ "+sgrReset)
+	}
+
+	l.body = rest
+	return l
+}
+
+// prefixKey identifies lines belonging to the same code block
+func (l themedLine) prefixKey() string {
+	return fmt.Sprintf("%s|%d", l.indent, l.quoteDepth)
+}
+
+// applyTheme restyles rendered markdown with the colors from the config
+func applyTheme(rendered []byte, colors *ColorConfig) []byte {
+	styles := newThemeStyles(colors)
+
+	lines := strings.Split(string(rendered), "\n")
+	parsed := make([]themedLine, len(lines))
+	for i, line := range lines {
+		parsed[i] = parseThemedLine(line)
+	}
+
+	// Code blocks get a solid background, so find the widest line of each block
+	codeWidths := make([]int, len(lines))
+	for start := 0; start < len(lines); {
+		if !parsed[start].code {
+			start++
+			continue
+		}
+		end := start
+		width := 0
+		for end < len(lines) && parsed[end].code && parsed[end].prefixKey() == parsed[start].prefixKey() {
+			width = max(width, lipgloss.Width(parsed[end].body))
+			end++
+		}
+		for i := start; i < end; i++ {
+			codeWidths[i] = width
+		}
+		start = end
+	}
+
+	headingLevel := 0
+	inTable := false
+	inTableHeader := false
+	// alertDepth is the quote depth of the current alert, 0 outside alerts
+	alertDepth := 0
+	alertStyle := ""
+
+	for i, l := range parsed {
+		if l.quoteDepth < alertDepth {
+			alertDepth = 0
+		}
+		alert := -1
+		if l.quoteDepth > 0 {
+			alert = findAlertType(l.body)
+		}
+		if alert >= 0 {
+			alertDepth = l.quoteDepth
+			alertStyle = styles.alerts[alert]
+		}
+
+		var prefix strings.Builder
+		prefix.WriteString(l.indent)
+		for d := 0; d < l.quoteDepth; d++ {
+			barStyle := styles.blockQuote
+			if d == alertDepth-1 {
+				barStyle = alertStyle
+			}
+			prefix.WriteString(barStyle + "┃ " + sgrReset)
+		}
+
+		base := ""
+		if l.quoteDepth > 0 {
+			base = styles.blockQuote
+		}
+
+		body := l.body
+		switch {
+		case alert >= 0:
+			headingLevel = 0
+			title := alertTypes[alert].icon + " " + alertTypes[alert].title
+			lines[i] = prefix.String() + "\x1b[1m" + alertStyle + title + sgrReset
+			continue
+
+		case l.code:
+			headingLevel = 0
+			prefix.WriteString(styles.codeGutter + "┃" + sgrReset)
+			fill := codeWidths[i] - lipgloss.Width(body)
+			body = " " + body + strings.Repeat(" ", max(fill, 0)+1)
+			lines[i] = prefix.String() + styles.codeBlock + reapplyAfterReset(body, styles.codeBlock) + sgrReset
+			continue
+
+		case headingPattern.MatchString(body):
+			number := headingPattern.FindStringSubmatch(body)[1]
+			headingLevel = min(strings.Count(number, ".")+1, 6)
+			base = styles.headings[headingLevel-1]
+			body = headingContPattern.ReplaceAllString(body, "")
+
+		case headingLevel > 0 && headingContPattern.MatchString(body):
+			base = styles.headings[headingLevel-1]
+			body = headingContPattern.ReplaceAllString(body, "")
+
+		case headingLevel == 1 && l.quoteDepth == 0 && ruleLinePattern.MatchString(body):
+			// Underline of a level 1 heading
+			lines[i] = prefix.String() + styles.headings[0] + body + sgrReset
+			headingLevel = 0
+			continue
+
+		case strings.HasPrefix(body, "┌"):
+			inTable = true
+			inTableHeader = true
+			headingLevel = 0
+			lines[i] = prefix.String() + styles.tableBorder + body + sgrReset
+			continue
+
+		case inTable && strings.HasPrefix(body, "│"):
+			rowStyle := styles.tableRow
+			if inTableHeader {
+				rowStyle = styles.tableHeader
+			}
+			lines[i] = prefix.String() + themeTableRow(body, rowStyle, styles)
+			continue
+
+		case inTable && (strings.HasPrefix(body, "╞") || strings.HasPrefix(body, "├") || strings.HasPrefix(body, "└")):
+			if strings.HasPrefix(body, "╞") {
+				inTableHeader = false
+			}
+			if strings.HasPrefix(body, "└") {
+				inTable = false
+			}
+			lines[i] = prefix.String() + styles.tableBorder + body + sgrReset
+			continue
+
+		default:
+			headingLevel = 0
+		}
+
+		lines[i] = prefix.String() + themeBody(body, base, styles)
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// themeBody styles the body of a line and makes sure its base style does
+// not leak into the next line
+func themeBody(body, base string, styles themeStyles) string {
+	trimmed := body
+	for strings.HasSuffix(trimmed, sgrReset) {
+		trimmed = strings.TrimSuffix(trimmed, sgrReset)
+	}
+	if trimmed == "" {
+		return body
+	}
+
+	styled := base + themeInline(trimmed, base, styles)
+	if base != "" || trimmed != body {
+		styled += sgrReset
+	}
+	return styled
+}
+
+// themeTableRow colors the borders and cells of a table row
+func themeTableRow(body, rowStyle string, styles themeStyles) string {
+	cells := strings.Split(body, "│")
+	var sb strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			sb.WriteString(styles.tableBorder + "│" + sgrReset)
+		}
+		if cell == "" {
+			continue
+		}
+		sb.WriteString(themeBody(cell, rowStyle, styles))
+	}
+	return sb.String()
+}
+
+// themeInline colors bold, italic and strikethrough spans and restores the
+// base style after every reset
+func themeInline(body, base string, styles themeStyles) string {
+	restore := base
+	if restore == "" {
+		restore = "\x1b[39m"
+	}
+
+	body = sgrPattern.ReplaceAllStringFunc(body, func(seq string) string {
+		params := sgrPattern.FindStringSubmatch(seq)[1]
+		out := seq
+		for _, attr := range sgrAttributes(params) {
+			switch attr {
+			case 0:
+				out += base
+			case 1:
+				out += styles.bold
+			case 3:
+				out += styles.italic
+			case 9:
+				out += styles.strikethrough
+			case 23, 29:
+				out += restore
+			}
+		}
+		return out
+	})
+
+	return taskPattern.ReplaceAllStringFunc(body, func(match string) string {
+		sub := taskPattern.FindStringSubmatch(match)
+		style := styles.taskUnchecked
+		if sub[2] != " " {
+			style = styles.taskChecked
+		}
+		return sub[1] + style + "[" + sub[2] + "]" + sgrReset + base
+	})
+}
+
+// sgrAttributes returns the attribute codes of an SGR parameter list,
+// skipping over extended color arguments
+func sgrAttributes(params string) []int {
+	if params == "" {
+		return []int{0}
+	}
+
+	var attrs []int
+	parts := strings.Split(params, ";")
+	for i := 0; i < len(parts); i++ {
+		code, err := strconv.Atoi(parts[i])
+		if err != nil {
+			continue
+		}
+		switch code {
+		case 38, 48, 58:
+			if i+1 < len(parts) && parts[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(parts) && parts[i+1] == "2" {
+				i += 4
+			}
+			continue
+		}
+		attrs = append(attrs, code)
+	}
+	return attrs
+}
+
+// reapplyAfterReset re-emits seq after every reset so that a style survives
+// the resets embedded in already-colored text
+func reapplyAfterReset(s, seq string) string {
+	if seq == "" {
+		return s
+	}
+	return sgrPattern.ReplaceAllStringFunc(s, func(match string) string {
+		for _, attr := range sgrAttributes(sgrPattern.FindStringSubmatch(match)[1]) {
+			if attr == 0 {
+				return match + seq
+			}
+		}
+		return match
+	})
+}
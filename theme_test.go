@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// renderSample renders the theme sample document the same way the viewer does
+func renderSample(t *testing.T, config *Config) []byte {
+	t.Helper()
+
+	sample, err := os.ReadFile(filepath.Join("testdata", "theme-sample.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useColorProfile(t, ProfileANSI256)
+	installRendererPalette(&config.Colors)
+
+	m := model{
+		raw:    string(sample),
+		width:  80,
+		config: config,
+	}
+	return m.render()
+}
+
+func loadThemeFile(t *testing.T, path string) *Config {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{Layout: DefaultLayout()}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	config.fillDefaults()
+	return &config
+}
+
+func TestThemeGolden(t *testing.T) {
+	themes, err := filepath.Glob(filepath.Join("themes", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(themes) == 0 {
+		t.Fatal("no themes found")
+	}
+
+	for _, theme := range themes {
+		name := strings.TrimSuffix(filepath.Base(theme), ".json")
+		t.Run(name, func(t *testing.T) {
+			got := renderSample(t, loadThemeFile(t, theme))
+
+			golden := filepath.Join("testdata", "themes", name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run go test -update: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("rendering differs from %s, run go test -update to regenerate", golden)
+			}
+		})
+	}
+}
+
+func TestThemeColorKeysStyleOutput(t *testing.T) {
+	keys := []string{
+		"heading1", "heading2", "heading3", "heading4", "heading5", "heading6",
+		"bold", "italic", "strikethrough", "link", "link_url",
+		"code", "code_block", "code_block_bg",
+		"list_marker", "task_checked", "task_unchecked",
+		"blockquote", "table_header", "table_row", "table_border",
+	}
+
+	baseline := renderSample(t, DefaultConfig())
+
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			config := DefaultConfig()
+			setColorKey(t, &config.Colors, key, "#5f005f")
+
+			if bytes.Equal(renderSample(t, config), baseline) {
+				t.Errorf("changing %q has no effect on the rendered document", key)
+			}
+		})
+	}
+}
+
+// setColorKey sets the ColorConfig field with the given JSON name
+func setColorKey(t *testing.T, colors *ColorConfig, key, value string) {
+	t.Helper()
+
+	v := reflect.ValueOf(colors).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("json") == key {
+			v.Field(i).SetString(value)
+			return
+		}
+	}
+	t.Fatalf("unknown color key %q", key)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bundledThemes holds the theme files shipped with bleamd
+//
+//go:embed themes/*.json
+var bundledThemes embed.FS
+
+// themeAliases maps alternative spellings to bundled theme names
+var themeAliases = map[string]string{
+	"onedark": "one-dark",
+}
+
+// ThemeNames returns the names of all built-in and user themes
+func ThemeNames() []string {
+	seen := map[string]bool{"default": true}
+	var names []string
+
+	if entries, err := bundledThemes.ReadDir("themes"); err == nil {
+		for _, entry := range entries {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+			seen[names[len(names)-1]] = true
+		}
+	}
+
+	// User themes live next to the config file, ~/.config/bleamd/themes/*.json
+	if entries, err := os.ReadDir(getUserThemesDir()); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+				continue
+			}
+			name := strings.TrimSuffix(entry.Name(), ".json")
+			if !seen[strings.ToLower(name)] {
+				names = append(names, name)
+				seen[strings.ToLower(name)] = true
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return append([]string{"default"}, names...)
+}
+
+// LoadTheme returns the configuration of a built-in or user theme. A user
+// theme takes precedence over a built-in one with the same name.
+func LoadTheme(name string) (*Config, error) {
+	config, err := resolveTheme(name, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	config.fillDefaults()
+
+	if err := config.validateSyntaxStyles(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// builtinSourcePrefix marks values coming from a theme embedded in the binary
+const builtinSourcePrefix = "built-in:"
+
+// resolveTheme loads the theme ref names, following its extends chain.
+// ref is either a theme name or the path of a theme file, relative paths
+// being resolved against dir. chain lists the files already being loaded and
+// is used to detect cycles.
+func resolveTheme(ref, dir string, chain []string) (*Config, error) {
+	var source string
+	var data []byte
+
+	if isThemePath(ref) {
+		var err error
+		source, err = resolveThemePath(ref, dir)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(chain, source) {
+			return nil, fmt.Errorf("theme cycle: %s", strings.Join(append(chain, source), " -> "))
+		}
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, fmt.Errorf("failed to read theme: %w", err)
+		}
+	} else {
+		builtin := strings.ToLower(ref)
+		if alias, ok := themeAliases[builtin]; ok {
+			builtin = alias
+		}
+
+		if builtin == "default" {
+			config := DefaultConfig()
+			config.setSources(builtinSourcePrefix + "default")
+			return config, nil
+		}
+
+		userPath := filepath.Join(getUserThemesDir(), ref+".json")
+		userData, userErr := os.ReadFile(userPath)
+		bundledData, bundledErr := bundledThemes.ReadFile(path.Join("themes", builtin+".json"))
+
+		switch {
+		// A user theme may extend the built-in theme it shadows
+		case userErr == nil && (!containsString(chain, userPath) || bundledErr != nil):
+			source, data = userPath, userData
+		case bundledErr == nil:
+			source, data = builtinSourcePrefix+builtin, bundledData
+		default:
+			return nil, fmt.Errorf("unknown theme: %s (available: %s)", ref, strings.Join(ThemeNames(), ", "))
+		}
+	}
+
+	if containsString(chain, source) {
+		return nil, fmt.Errorf("theme cycle: %s", strings.Join(append(chain, source), " -> "))
+	}
+
+	themeDir := filepath.Dir(source)
+	if strings.HasPrefix(source, builtinSourcePrefix) {
+		themeDir = ""
+	}
+	return parseThemeLayer(data, source, themeDir, chain)
+}
+
+// parseThemeLayer parses one file of a theme chain on top of the theme it
+// extends. The result has no defaults filled in, so that the caller can tell
+// which values were set.
+func parseThemeLayer(data []byte, source, dir string, chain []string) (*Config, error) {
+	// Look for the base theme first, the rest of the file overrides its values
+	var header struct {
+		Extends    string `json:"extends"`
+		Theme      string `json:"theme"`
+		LightTheme string `json:"light_theme"`
+		DarkTheme  string `json:"dark_theme"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+
+	base, themeSource := header.Extends, ""
+	if header.Theme != "" || header.LightTheme != "" || header.DarkTheme != "" {
+		if header.Extends != "" {
+			return nil, fmt.Errorf("%s: extends can't be combined with theme, light_theme or dark_theme", source)
+		}
+		base, themeSource = header.Theme, "theme"
+		// Only query the terminal when the choice depends on it
+		if header.LightTheme != "" || header.DarkTheme != "" {
+			base, themeSource = selectTheme(header.Theme, header.LightTheme, header.DarkTheme, currentBackground())
+		}
+	}
+
+	// Layout values can legitimately be zero, so they start from the defaults
+	// rather than being filled in afterwards
+	config := &Config{Layout: DefaultLayout()}
+	if base != "" {
+		var err error
+		config, err = resolveTheme(base, dir, append(chain, source))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	config.Extends = header.Extends
+	if themeSource != "" {
+		config.Theme = base
+		config.themeSource = themeSource
+	}
+	config.recordSources(data, source)
+
+	return config, nil
+}
+
+// isThemePath tells whether an extends value is a file path rather than a
+// theme name
+func isThemePath(ref string) bool {
+	return strings.ContainsAny(ref, `/\`) || strings.HasSuffix(ref, ".json")
+}
+
+// resolveThemePath makes a theme path absolute, expanding ~ and resolving
+// relative paths against dir
+func resolveThemePath(ref, dir string) (string, error) {
+	if strings.HasPrefix(ref, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		ref = filepath.Join(home, ref[2:])
+	}
+	if filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	if dir == "" {
+		return "", fmt.Errorf("built-in themes can't extend %s", ref)
+	}
+	return filepath.Abs(filepath.Join(dir, ref))
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nextThemeName returns the theme following current in ThemeNames, wrapping
+// around at the end. An unknown or empty current name starts at the first theme.
+func nextThemeName(current string) string {
+	names := ThemeNames()
+
+	if alias, ok := themeAliases[strings.ToLower(current)]; ok {
+		current = alias
+	}
+
+	for i, name := range names {
+		if strings.EqualFold(name, current) {
+			return names[(i+1)%len(names)]
+		}
+	}
+	return names[0]
+}
+
+// UseTheme replaces the colors and syntax style of the config with those of
+// a theme, keeping every other setting
+func (c *Config) UseTheme(name string) error {
+	theme, err := LoadTheme(name)
+	if err != nil {
+		return err
+	}
+
+	c.Theme = name
+	c.themeSource = ""
+	c.Colors = theme.Colors
+	c.SyntaxStyle = theme.SyntaxStyle
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	for _, key := range configKeys() {
+		if !strings.HasPrefix(key, "colors.") && key != "syntax_style" {
+			continue
+		}
+		if source, ok := theme.sources[key]; ok {
+			c.sources[key] = source
+		} else {
+			delete(c.sources, key)
+		}
+	}
+	return nil
+}
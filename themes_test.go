@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadTheme(t *testing.T) {
+	for _, name := range ThemeNames() {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadTheme(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Colors.Heading1 == "" {
+				t.Error("Expected heading1 to be set")
+			}
+			if len(config.Keybindings.Quit) == 0 {
+				t.Error("Expected default keybindings")
+			}
+		})
+	}
+
+	dracula, err := LoadTheme("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dracula.Colors.Heading1 != "#bd93f9" {
+		t.Errorf("Expected dracula heading1 %q, got %q", "#bd93f9", dracula.Colors.Heading1)
+	}
+
+	if _, err := LoadTheme("onedark"); err != nil {
+		t.Errorf("Expected the onedark alias to load: %v", err)
+	}
+
+	if _, err := LoadTheme("no-such-theme"); err == nil {
+		t.Error("Expected an error for an unknown theme")
+	}
+}
+
+func TestParseConfigLayersTheme(t *testing.T) {
+	data := []byte(`{
+		"theme": "dracula",
+		"colors": {"heading1": "#010203"},
+		"keybindings": {"quit": ["x"]}
+	}`)
+
+	config, err := parseConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Theme != "dracula" {
+		t.Errorf("Expected theme %q, got %q", "dracula", config.Theme)
+	}
+	if config.Colors.Heading1 != "#010203" {
+		t.Errorf("Expected user override for heading1, got %q", config.Colors.Heading1)
+	}
+	if config.Colors.Heading2 != "#ff79c6" {
+		t.Errorf("Expected dracula heading2, got %q", config.Colors.Heading2)
+	}
+	if len(config.Keybindings.Quit) != 1 || config.Keybindings.Quit[0] != "x" {
+		t.Errorf("Expected user quit binding, got %v", config.Keybindings.Quit)
+	}
+
+	if _, err := parseConfig([]byte(`{"theme": "no-such-theme"}`)); err == nil {
+		t.Error("Expected an error for an unknown theme")
+	}
+}
+
+func TestUseTheme(t *testing.T) {
+	config := DefaultConfig()
+	config.Keybindings.Quit = []string{"x"}
+
+	if err := config.UseTheme("solarized-dark"); err != nil {
+		t.Fatal(err)
+	}
+
+	solarized, _ := LoadTheme("solarized-dark")
+	if config.Colors != solarized.Colors {
+		t.Error("Expected the colors of the theme")
+	}
+	if config.Keybindings.Quit[0] != "x" {
+		t.Error("Expected keybindings to be kept")
+	}
+}
+
+func TestUserThemes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dir := getUserThemesDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mine.json"), []byte(`{"colors": {"heading1": "#010203"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dracula.json"), []byte(`{"colors": {"heading1": "#040506"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names := ThemeNames()
+	count := 0
+	for _, name := range names {
+		if name == "dracula" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected dracula to be listed once, got %v", names)
+	}
+
+	mine, err := LoadTheme("mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mine.Colors.Heading1 != "#010203" || mine.Colors.Heading2 == "" {
+		t.Errorf("Expected the user theme layered on the defaults, got %+v", mine.Colors)
+	}
+
+	dracula, err := LoadTheme("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dracula.Colors.Heading1 != "#040506" {
+		t.Errorf("Expected the user theme to shadow the built-in one, got %q", dracula.Colors.Heading1)
+	}
+}
+
+func TestGeneratedConfigTakesTheme(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// The first run writes the config file
+	if _, err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pick another theme in it, as a user would
+	var file map[string]interface{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file["theme"] = "dracula"
+	data, err = json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(getConfigPath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dracula, err := LoadTheme("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Colors != dracula.Colors {
+		t.Errorf("Expected the colors of dracula, got heading1 %q instead of %q", config.Colors.Heading1, dracula.Colors.Heading1)
+	}
+	if config.SyntaxStyle != dracula.SyntaxStyle {
+		t.Errorf("Expected the syntax style of dracula, got %q instead of %q", config.SyntaxStyle, dracula.SyntaxStyle)
+	}
+	if !reflect.DeepEqual(config.Keybindings, DefaultKeybindings()) || config.Layout != DefaultLayout() {
+		t.Errorf("Expected the generated keybindings and layout to be kept")
+	}
+}
+
+func TestNextThemeName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	names := ThemeNames()
+	tests := []struct {
+		current  string
+		expected string
+	}{
+		{"", names[0]},
+		{"no-such-theme", names[0]},
+		{names[0], names[1]},
+		{names[len(names)-1], names[0]},
+		{"onedark", nextThemeName("one-dark")},
+	}
+
+	for _, tt := range tests {
+		if got := nextThemeName(tt.current); got != tt.expected {
+			t.Errorf("nextThemeName(%q) = %q, expected %q", tt.current, got, tt.expected)
+		}
+	}
+}
+
+func TestCycleTheme(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useColorProfile(t, ProfileANSI256)
+
+	raw, err := os.ReadFile("testdata/theme-sample.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.Theme = "default"
+	m := newModel(raw, config)
+	m.height = 10
+	m.yOffset = 5
+	before := string(m.renderedContent)
+
+	m = m.cycleTheme()
+
+	if m.config.Theme != nextThemeName("default") {
+		t.Errorf("Expected theme %q, got %q", nextThemeName("default"), m.config.Theme)
+	}
+	if m.yOffset != 5 {
+		t.Errorf("Expected the scroll position to be kept, got %d", m.yOffset)
+	}
+	if string(m.renderedContent) == before {
+		t.Error("Expected the content to be rendered with the new theme")
+	}
+	if !strings.Contains(m.renderStatusBar(), "theme:"+m.config.Theme) {
+		t.Errorf("Expected the status bar to show the theme, got %q", m.renderStatusBar())
+	}
+}
+
+// writeThemeFile writes a theme or config file, creating its directory
+func writeThemeFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtendsChain(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	team := filepath.Join(filepath.Dir(getConfigPath()), "team", "base.json")
+	writeThemeFile(t, team, `{"extends": "one-dark", "colors": {"heading1": "#010101"}}`)
+
+	config, err := parseConfig([]byte(`{
+		"extends": "team/base.json",
+		"colors": {"bold": "#020202"},
+		"keybindings": {"quit": ["x"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oneDark, _ := LoadTheme("one-dark")
+	tests := []struct {
+		key, value, source string
+	}{
+		{"colors.heading1", config.Colors.Heading1, team},
+		{"colors.bold", config.Colors.Bold, getConfigPath()},
+		{"colors.heading2", config.Colors.Heading2, "built-in:one-dark"},
+		{"keybindings.quit", config.Keybindings.Quit[0], getConfigPath()},
+		{"keybindings.scroll_up", config.Keybindings.ScrollUp[0], "defaults"},
+	}
+	for _, tt := range tests {
+		if got := config.ValueSource(tt.key); got != tt.source {
+			t.Errorf("ValueSource(%q) = %q, expected %q", tt.key, got, tt.source)
+		}
+	}
+
+	if config.Colors.Heading1 != "#010101" || config.Colors.Bold != "#020202" || config.Colors.Heading2 != oneDark.Colors.Heading2 {
+		t.Errorf("Expected values from every layer, got %+v", config.Colors)
+	}
+	if config.Colors.Link != oneDark.Colors.Link {
+		t.Errorf("Expected missing values from the extended theme, got link %q", config.Colors.Link)
+	}
+}
+
+func TestExtendsErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := filepath.Dir(getConfigPath())
+
+	writeThemeFile(t, filepath.Join(dir, "a.json"), `{"extends": "b.json"}`)
+	writeThemeFile(t, filepath.Join(dir, "b.json"), `{"extends": "./a.json"}`)
+	writeThemeFile(t, filepath.Join(getUserThemesDir(), "loop.json"), `{"extends": "loop2"}`)
+	writeThemeFile(t, filepath.Join(getUserThemesDir(), "loop2.json"), `{"extends": "loop"}`)
+
+	tests := []struct {
+		name   string
+		config string
+		errMsg string
+	}{
+		{"file cycle", `{"extends": "a.json"}`, "theme cycle"},
+		{"theme cycle", `{"extends": "loop"}`, "theme cycle"},
+		{"self", `{"extends": "config.json"}`, "theme cycle"},
+		{"missing file", `{"extends": "missing.json"}`, "failed to read theme"},
+		{"unknown theme", `{"extends": "no-such-theme"}`, "unknown theme"},
+		{"extends and theme", `{"extends": "dracula", "theme": "one-dark"}`, "can't be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig([]byte(tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestUserThemeExtendsShadowedBuiltin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeThemeFile(t, filepath.Join(getUserThemesDir(), "dracula.json"), `{"extends": "dracula", "colors": {"heading1": "#030303"}}`)
+
+	config, err := LoadTheme("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Colors.Heading1 != "#030303" {
+		t.Errorf("Expected the user override, got heading1 %q", config.Colors.Heading1)
+	}
+	if config.Colors.Heading2 != "#ff79c6" {
+		t.Errorf("Expected the built-in dracula heading2, got %q", config.Colors.Heading2)
+	}
+	if got := config.ValueSource("colors.heading2"); got != "built-in:dracula" {
+		t.Errorf("Expected heading2 from the built-in theme, got %q", got)
+	}
+}
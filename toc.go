@@ -0,0 +1,272 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// The table of contents pane lists the headings of the document on the right
+// of the text, which is rendered narrower while the pane is shown. The entry
+// of the section holding the top of the screen is marked. While the pane has
+// the focus, the scroll keys move its cursor and Enter scrolls the document
+// to the heading under it; a click on an entry does the same.
+//
+// Headings are found by marking their text with headingMarker before
+// rendering. The marker follows the number go-term-markdown gives the
+// heading, which tells its level, and is removed once the lines are final.
+
+// headingMarker is a private use character that never appears in documents
+const headingMarker = "\uE006"
+
+// minTOCWidth keeps the titles readable in the pane
+const minTOCWidth = 10
+
+var (
+	atxHeadingPattern      = regexp.MustCompile(`^( {0,3}#{1,6}[ \t]+)(\S.*)$`)
+	setextUnderlinePattern = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	paragraphStartPattern  = regexp.MustCompile(`^ {0,3}[^\s>#|<*+\-=\d]|^ {0,3}\d+[^\d.)]`)
+	markedHeadingPattern   = regexp.MustCompile(`(\d+(?:\.\d+)*) ` + headingMarker)
+)
+
+// heading is a heading of the rendered document
+type heading struct {
+	level int
+	title string
+	// line is the line of the heading in the rendered document
+	line int
+}
+
+// processHeadings marks the text of the ATX and setext headings of markdown
+// with headingMarker
+func processHeadings(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	var fences codeFences
+	for i, line := range lines {
+		if fences.inCode(line) {
+			continue
+		}
+
+		if match := atxHeadingPattern.FindStringSubmatch(line); match != nil {
+			lines[i] = match[1] + headingMarker + match[2]
+			continue
+		}
+
+		// A setext underline makes a heading of the paragraph line above
+		if i > 0 && setextUnderlinePattern.MatchString(line) && paragraphStartPattern.MatchString(lines[i-1]) &&
+			(i < 2 || strings.TrimSpace(lines[i-2]) == "") {
+			trimmed := strings.TrimLeft(lines[i-1], " ")
+			lines[i-1] = lines[i-1][:len(lines[i-1])-len(trimmed)] + headingMarker + trimmed
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indexHeadings removes the heading markers of rendered, returning the
+// headings they marked
+func indexHeadings(rendered []byte) ([]byte, []heading) {
+	if !strings.Contains(string(rendered), headingMarker) {
+		return rendered, nil
+	}
+
+	var headings []heading
+	lines := strings.Split(string(rendered), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, headingMarker) {
+			continue
+		}
+		plain := stripANSI(line)
+		if match := markedHeadingPattern.FindStringSubmatch(plain); match != nil {
+			title := plain[strings.Index(plain, headingMarker)+len(headingMarker):]
+			headings = append(headings, heading{
+				level: strings.Count(match[1], ".") + 1,
+				title: strings.TrimSpace(title),
+				line:  i,
+			})
+		}
+		lines[i] = strings.ReplaceAll(line, headingMarker, "")
+	}
+	return []byte(strings.Join(lines, "\n")), headings
+}
+
+// tocWidth returns the width of the pane, border included, or 0 when it is
+// hidden
+func (m model) tocWidth() int {
+	if !m.tocActive {
+		return 0
+	}
+	return max(min(m.config.Layout.TOCWidth, m.width/2), minTOCWidth)
+}
+
+// contentWidth returns the width left to the text
+func (m model) contentWidth() int {
+	return m.width - m.tocWidth()
+}
+
+// currentSection returns the heading of the section holding the top of the
+// screen, or -1 above the first heading
+func (m model) currentSection() int {
+	current := -1
+	for i, h := range m.headings {
+		if h.line > m.yOffset {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+// tocWindow returns the first entry shown in a pane of height lines, keeping
+// the cursor, or else the current section, in view
+func (m model) tocWindow(height int) int {
+	rows := height - 1 // the title takes a line
+	target := m.currentSection()
+	if m.tocFocused {
+		target = m.tocCursor
+	}
+	return max(min(target-rows/2, len(m.headings)-rows), 0)
+}
+
+// renderTOC returns the lines of a pane of height lines
+func (m model) renderTOC(height int) []string {
+	width := m.tocWidth()
+	colors := &m.config.Colors
+	levels := []string{colors.Heading1, colors.Heading2, colors.Heading3, colors.Heading4, colors.Heading5, colors.Heading6}
+	border := colors.GetANSIColor(colors.TableBorder)
+
+	top := 6
+	for _, h := range m.headings {
+		top = min(top, h.level)
+	}
+
+	rows := []string{"\x1b[1mContents" + sgrReset}
+	current := m.currentSection()
+	for i := m.tocWindow(height); i < len(m.headings) && len(rows) < height; i++ {
+		h := m.headings[i]
+		marker := " "
+		if i == current {
+			marker = "›"
+		}
+		entry := marker + strings.Repeat(" ", 2*(h.level-top)) + h.title
+		entry = runewidth.Truncate(entry, width-2, "…")
+		entry += strings.Repeat(" ", max(width-2-runewidth.StringWidth(entry), 0))
+
+		style := colors.GetANSIColor(levels[h.level-1])
+		if i == current {
+			style += "\x1b[1m"
+		}
+		if m.tocFocused && i == m.tocCursor {
+			style += "\x1b[7m"
+		}
+		rows = append(rows, style+entry+sgrReset)
+	}
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+
+	for i, row := range rows {
+		rows[i] = border + "│" + sgrReset + " " + row
+	}
+	return rows
+}
+
+// besideTOC puts the pane on the right of the visible lines of the text,
+// cutting or padding them to the width of the text
+func (m model) besideTOC(lines []string, height int) []string {
+	if !m.tocActive || height <= 0 {
+		return lines
+	}
+	width := m.contentWidth()
+	pane := m.renderTOC(height)
+	out := make([]string, height)
+	for i := range out {
+		line := ""
+		if i < len(lines) {
+			line = lines[i]
+		}
+		if ansi.StringWidth(line) > width {
+			line = ansi.Truncate(line, width, "") + sgrReset + "\x1b]8;;\x1b\\"
+		}
+		out[i] = line + strings.Repeat(" ", max(width-ansi.StringWidth(line), 0)) + pane[i]
+	}
+	return out
+}
+
+// toggleTOC shows the pane with the focus on the current section, or hides
+// it, rendering the text at its new width
+func (m model) toggleTOC() model {
+	m.tocActive = !m.tocActive
+	m.tocFocused = m.tocActive
+	m.tocCursor = max(m.currentSection(), 0)
+
+	// Keep the same heading on top of the screen
+	section := m.currentSection()
+	m = m.refresh()
+	if section >= 0 && section < len(m.headings) {
+		m.yOffset = m.headings[section].line
+	}
+	m.yOffset = max(0, min(m.yOffset, m.lines-m.height+1))
+	return m.updateLinkPositions()
+}
+
+// goToHeading scrolls the document to the i-th heading
+func (m model) goToHeading(i int) model {
+	if i < 0 || i >= len(m.headings) {
+		return m
+	}
+	m.tocCursor = i
+	return m.scrollToLine(m.headings[i].line).updateLinkPositions()
+}
+
+// handleTOCKey handles the keys moving through the pane while it has the
+// focus, moving count entries or pages at a time. handled is false for the
+// other keys.
+func (m model) handleTOCKey(key string, count int) (model, bool) {
+	last := len(m.headings) - 1
+	steps := max(count, 1)
+	page := max(m.height-4, 1) * steps
+	switch {
+	case key == "enter":
+		return m.goToHeading(m.tocCursor), true
+	case key == "tab":
+		m.tocFocused = false
+		return m, true
+	case key == "esc":
+		return m.toggleTOC(), true
+	case m.isKeyInSlice(key, m.config.Keybindings.ScrollUp):
+		m.tocCursor = max(m.tocCursor-steps, 0)
+	case m.isKeyInSlice(key, m.config.Keybindings.ScrollDown):
+		m.tocCursor = max(min(m.tocCursor+steps, last), 0)
+	case m.isKeyInSlice(key, m.config.Keybindings.PageUp):
+		m.tocCursor = max(m.tocCursor-page, 0)
+	case m.isKeyInSlice(key, m.config.Keybindings.PageDown):
+		m.tocCursor = max(min(m.tocCursor+page, last), 0)
+	case m.isKeyInSlice(key, m.config.Keybindings.GoToTop), m.isKeyInSlice(key, m.config.Keybindings.GoToBottom) && count > 0:
+		// Like 42G for lines, a count goes to that entry
+		m.tocCursor = max(min(count-1, last), 0)
+	case m.isKeyInSlice(key, m.config.Keybindings.GoToBottom):
+		m.tocCursor = max(last, 0)
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// clickTOC goes to the heading of the pane entry at row y
+func (m model) clickTOC(y int) model {
+	// Calculate visible height (same as in View())
+	height := m.height - 2
+	if m.searchActive {
+		height -= 3
+	}
+	if m.search.term != "" {
+		height -= 1
+	}
+	if y < 1 || y >= height {
+		return m
+	}
+	m.tocFocused = true
+	return m.goToHeading(m.tocWindow(height) + y - 1)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestProcessHeadings(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "atx",
+			input:    "# One\n\n  ### Three ###\n\n#NoSpace\n",
+			expected: "# " + headingMarker + "One\n\n  ### " + headingMarker + "Three ###\n\n#NoSpace\n",
+		},
+		{
+			name:     "setext",
+			input:    "Title\n=====\n\nSub\n---\n",
+			expected: headingMarker + "Title\n=====\n\n" + headingMarker + "Sub\n---\n",
+		},
+		{
+			name:     "rules and lists",
+			input:    "Text\n\n---\n\n- item\n---\n",
+			expected: "Text\n\n---\n\n- item\n---\n",
+		},
+		{
+			name:     "code fences",
+			input:    "```sh\n# comment\n```\n",
+			expected: "```sh\n# comment\n```\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processHeadings(tt.input); got != tt.expected {
+				t.Errorf("processHeadings() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIndexHeadings(t *testing.T) {
+	rendered := "\x1b[1m1 " + headingMarker + "Title\x1b[0m\n\ntext\n\x1b[1m0.1 " + headingMarker + "Skipped\x1b[0m\n1.2.3 " + headingMarker + "Deep \n"
+	got, headings := indexHeadings([]byte(rendered))
+	if strings.Contains(string(got), headingMarker) {
+		t.Errorf("indexHeadings() left markers in %q", got)
+	}
+	expected := []heading{
+		{level: 1, title: "Title", line: 0},
+		{level: 2, title: "Skipped", line: 3},
+		{level: 3, title: "Deep", line: 4},
+	}
+	if !reflect.DeepEqual(headings, expected) {
+		t.Errorf("indexHeadings() = %+v, expected %+v", headings, expected)
+	}
+}
+
+func TestTOCPane(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	raw := "# Title\n\nIntro.\n\n" + strings.Repeat("Filler.\n\n", 20) + "## Usage\n\n" + strings.Repeat("More.\n\n", 20) + "### Options\n\nEnd.\n"
+	m := newModel([]byte(raw), DefaultConfig())
+	m.width = 80
+	m.height = 12
+	m = m.refresh()
+	if len(m.headings) != 3 {
+		t.Fatalf("Expected 3 headings, got %+v", m.headings)
+	}
+	fullWidth := strings.Count(stripANSI(strings.Split(string(m.renderedContent), "\n")[1]), "─")
+
+	// The pane opens focused on the current section, beside narrower text
+	updated, _ := m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m = updated.(model)
+	if !m.tocActive || !m.tocFocused || m.tocCursor != 0 {
+		t.Fatalf("Expected the pane focused on the first heading, got active=%v focused=%v cursor=%d", m.tocActive, m.tocFocused, m.tocCursor)
+	}
+	if width := strings.Count(stripANSI(strings.Split(string(m.renderedContent), "\n")[1]), "─"); width >= fullWidth {
+		t.Errorf("Expected the text narrower than %d columns, got %d", fullWidth, width)
+	}
+	view := stripANSI(m.View())
+	for _, expected := range []string{"│ Contents", "│ ›Title", "│    Usage", "│      Options"} {
+		if !strings.Contains(view, expected) {
+			t.Errorf("Expected %q in the view, got:\n%s", expected, view)
+		}
+	}
+
+	// A count moves that many entries, and back to the top
+	for _, key := range []string{"5", "j"} {
+		updated, _ = m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(model)
+	}
+	if m.tocCursor != 2 {
+		t.Errorf("Expected 5j to stop on the last heading, got cursor=%d", m.tocCursor)
+	}
+	for _, key := range []string{"2", "k"} {
+		updated, _ = m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(model)
+	}
+	if m.tocCursor != 0 {
+		t.Fatalf("Expected 2k to go back to the first heading, got cursor=%d", m.tocCursor)
+	}
+
+	// Move down and jump to the heading
+	for _, key := range []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune("j")}, {Type: tea.KeyEnter}} {
+		updated, _ = m.handleKeyMsg(key)
+		m = updated.(model)
+	}
+	if expected := m.scrollToLine(m.headings[1].line).yOffset; m.yOffset != expected {
+		t.Errorf("yOffset = %d, expected %d", m.yOffset, expected)
+	}
+
+	// Click the last entry
+	updated, _ = m.handleMouseMsg(tea.MouseMsg{X: m.width - 5, Y: 3, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+	m = updated.(model)
+	if m.tocCursor != 2 || m.yOffset != m.scrollToLine(m.headings[2].line).yOffset {
+		t.Errorf("Expected a click to go to the third heading, got cursor=%d yOffset=%d", m.tocCursor, m.yOffset)
+	}
+
+	// The toggle key closes the pane and renders the text at full width again
+	updated, _ = m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m = updated.(model)
+	if m.tocActive || m.tocFocused {
+		t.Errorf("Expected the pane closed")
+	}
+	if width := strings.Count(stripANSI(strings.Split(string(m.renderedContent), "\n")[1]), "─"); width != fullWidth {
+		t.Errorf("Expected the text back to %d columns, got %d", fullWidth, width)
+	}
+}
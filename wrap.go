@@ -0,0 +1,332 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	text "github.com/MichaelMure/go-term-text"
+	"github.com/mattn/go-runewidth"
+)
+
+// go-term-markdown counts the "(url)" part of links when it wraps paragraphs,
+// but addHyperlinks hides it behind OSC 8 sequences. Paragraphs are wrapped
+// again in two steps:
+//   1. unwrapParagraphs joins the lines go-term-markdown wrapped, so that each
+//      paragraph is back on a single line.
+//   2. wrapParagraphs wraps these lines on their visible text, once the links
+//      are hyperlinks.
+// Code blocks, tables, rules and images keep the renderer's line breaks.
+
+var (
+	listMarkerPattern = regexp.MustCompile(`^(?:\x1b\[[0-9;]*m)*(?:•|\d+\.) (?:\x1b\[[0-9;]*m)*`)
+	imagePixelPattern = regexp.MustCompile(`\x1b\[48;2;\d+;\d+;\d+m\x1b\[38;2;\d+;\d+;\d+m`)
+)
+
+// lineWrap describes how a rendered line may be wrapped
+type lineWrap struct {
+	// prose is set for running text, the only lines that are wrapped
+	prose bool
+	// bars is the width of the padding and quote bars, repeated on every
+	// continuation line
+	bars int
+	// hang is the width of everything before the text, list marker included
+	hang int
+	// item is set on the first line of a list item
+	item bool
+}
+
+// parseLineWrap splits a line rendered by go-term-markdown into its prefix
+// and text, and tells whether it can be wrapped
+func parseLineWrap(line string) (lineWrap, string) {
+	var w lineWrap
+	rest := line
+	for {
+		trimmed := strings.TrimLeft(rest, " ")
+		w.hang += len(rest) - len(trimmed)
+		rest = trimmed
+
+		loc := quoteBarPattern.FindStringIndex(rest)
+		if loc == nil {
+			break
+		}
+		w.hang += 2
+		w.bars = w.hang
+		rest = rest[loc[1]:]
+	}
+	if marker := listMarkerPattern.FindString(rest); marker != "" {
+		w.hang += text.Len(marker)
+		w.item = true
+		rest = rest[len(marker):]
+	}
+
+	plain := stripANSI(rest)
+	first, _ := utf8.DecodeRuneInString(plain)
+	w.prose = plain != "" &&
+		!strings.HasPrefix(rest, codeBlockGutterOpen) &&
+		!strings.ContainsRune("┌│╞├└", first) &&
+		!ruleLinePattern.MatchString(plain) &&
+		!imagePixelPattern.MatchString(rest)
+	return w, rest
+}
+
+// unwrapParagraphs joins the lines go-term-markdown wrapped at width. It
+// returns the joined output and how each of its lines may be wrapped.
+func unwrapParagraphs(rendered []byte, width int) ([]byte, []lineWrap) {
+	lines := strings.Split(string(rendered), "\n")
+
+	var out []string
+	var wraps []lineWrap
+	for i := 0; i < len(lines); i++ {
+		w, content := parseLineWrap(lines[i])
+		prefix := lines[i][:len(lines[i])-len(content)]
+		last := lines[i]
+
+		for w.prose && i+1 < len(lines) {
+			next, nextContent := parseLineWrap(lines[i+1])
+			if !next.prose || next.item || next.bars != w.bars || next.hang != w.hang {
+				break
+			}
+
+			// The renderer only breaks the line if the next word didn't fit
+			nextPlain := stripANSI(nextContent)
+			firstWord, _, _ := strings.Cut(nextPlain, " ")
+			if text.Len(last)+1+text.Len(firstWord) <= width {
+				break
+			}
+
+			// On a break, the renderer resets the style at the end of the line
+			// and sets it again after the padding
+			var state text.EscapeState
+			state.Witness(strings.TrimSuffix(content, sgrReset))
+			if !state.IsZero() && strings.HasSuffix(content, sgrReset) && strings.HasPrefix(nextContent, state.String()) {
+				content = strings.TrimSuffix(content, sgrReset)
+				nextContent = strings.TrimPrefix(nextContent, state.String())
+			}
+
+			// Words longer than a line are split without a space
+			lastPlain := stripANSI(content)
+			lastWord := lastPlain[strings.LastIndex(lastPlain, " ")+1:]
+			separator := " "
+			if text.Len(last) == width && text.Len(lastWord)+text.Len(firstWord) > width-w.hang {
+				separator = ""
+			}
+
+			content += separator + nextContent
+			last = lines[i+1]
+			i++
+		}
+
+		out = append(out, prefix+content)
+		wraps = append(wraps, w)
+	}
+
+	return []byte(strings.Join(out, "\n")), wraps
+}
+
+// wrapParagraphs wraps the prose lines of rendered to width columns, counting
+// only the visible text. wraps comes from unwrapParagraphs.
+func wrapParagraphs(rendered []byte, wraps []lineWrap, width int) []byte {
+	lines := strings.Split(string(rendered), "\n")
+	if len(lines) != len(wraps) {
+		return rendered
+	}
+
+	for i, line := range lines {
+		if wraps[i].prose && runewidth.StringWidth(stripANSI(line)) > width {
+			lines[i] = wrapLine(line, wraps[i], width)
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// wrapLine breaks a single line at spaces, or inside words longer than the
+// line. Styles and hyperlinks open at a break are closed at the end of the
+// line and opened again on the next one.
+func wrapLine(line string, w lineWrap, width int) string {
+	head, body := cutColumns(line, w.hang)
+	bars, _ := cutColumns(head, w.bars)
+	indent := bars + strings.Repeat(" ", w.hang-w.bars)
+	available := max(width-w.hang, 1)
+
+	var out strings.Builder
+	var style, link string
+	column := 0
+
+	witness := func(seq string) {
+		switch {
+		case strings.HasPrefix(seq, "\x1b]8;"):
+			link = seq
+			if seq == "\x1b]8;;\x1b\\" {
+				link = ""
+			}
+		case seq == sgrReset || seq == "\x1b[m":
+			style = ""
+		case strings.HasSuffix(seq, "m"):
+			style += seq
+		}
+	}
+	lineBreak := func() {
+		if link != "" {
+			out.WriteString("\x1b]8;;\x1b\\")
+		}
+		if style != "" {
+			out.WriteString(sgrReset)
+		}
+		out.WriteString("\n" + indent + style + link)
+		column = 0
+	}
+	// write outputs a space or a word, breaking inside words that are
+	// longer than the line
+	write := func(segment string) {
+		for segment != "" {
+			token := nextToken(segment)
+			segment = segment[len(token):]
+			if token[0] == '\x1b' {
+				out.WriteString(token)
+				witness(token)
+				continue
+			}
+			r, _ := utf8.DecodeRuneInString(token)
+			if column > 0 && column+runewidth.RuneWidth(r) > available {
+				lineBreak()
+			}
+			out.WriteString(token)
+			column += runewidth.RuneWidth(r)
+		}
+	}
+
+	for _, token := range tokenize(head) {
+		if token[0] == '\x1b' {
+			witness(token)
+		}
+	}
+	out.WriteString(head)
+
+	spaces := ""
+	for _, segment := range splitWords(body) {
+		segmentWidth := runewidth.StringWidth(stripANSI(segment))
+		if strings.TrimSpace(stripANSI(segment)) == "" {
+			spaces += segment
+			continue
+		}
+
+		spacesWidth := runewidth.StringWidth(stripANSI(spaces))
+		fits := column+spacesWidth+segmentWidth <= available
+		if column > 0 && !fits && (segmentWidth <= available || column+spacesWidth >= available) {
+			// Keep the styles set in the dropped spaces
+			for _, token := range tokenize(spaces) {
+				if token[0] == '\x1b' {
+					out.WriteString(token)
+					witness(token)
+				}
+			}
+			lineBreak()
+		} else {
+			write(spaces)
+		}
+		spaces = ""
+		write(segment)
+	}
+	write(spaces)
+
+	return out.String()
+}
+
+// splitWords splits s into alternating runs of spaces and words. Escape
+// sequences stay with the word they precede or follow.
+func splitWords(s string) []string {
+	var segments []string
+	var current, pending strings.Builder
+	inSpaces := false
+	for _, token := range tokenize(s) {
+		if token[0] == '\x1b' {
+			if inSpaces {
+				pending.WriteString(token)
+			} else {
+				current.WriteString(token)
+			}
+			continue
+		}
+		if (token == " ") != inSpaces {
+			if current.Len() > 0 {
+				segments = append(segments, current.String())
+				current.Reset()
+			}
+			inSpaces = token == " "
+		}
+		current.WriteString(pending.String())
+		pending.Reset()
+		current.WriteString(token)
+	}
+	current.WriteString(pending.String())
+	if current.Len() > 0 {
+		segments = append(segments, current.String())
+	}
+	return segments
+}
+
+// cutColumns splits s after n visible columns. Resets right after the cut
+// stay with the first part.
+func cutColumns(s string, n int) (string, string) {
+	column := 0
+	i := 0
+	for i < len(s) {
+		token := nextToken(s[i:])
+		if token[0] == '\x1b' {
+			if column >= n && token != sgrReset {
+				break
+			}
+		} else {
+			if column >= n {
+				break
+			}
+			r, _ := utf8.DecodeRuneInString(token)
+			column += runewidth.RuneWidth(r)
+		}
+		i += len(token)
+	}
+	return s[:i], s[i:]
+}
+
+// tokenize splits s into escape sequences and single characters
+func tokenize(s string) []string {
+	var tokens []string
+	for s != "" {
+		token := nextToken(s)
+		tokens = append(tokens, token)
+		s = s[len(token):]
+	}
+	return tokens
+}
+
+// nextToken returns the escape sequence or the character s starts with
+func nextToken(s string) string {
+	if len(s) < 2 || s[0] != '\x1b' {
+		_, size := utf8.DecodeRuneInString(s)
+		return s[:size]
+	}
+
+	switch s[1] {
+	case '[':
+		// CSI, ends with a byte in the 0x40-0x7e range
+		for j := 2; j < len(s); j++ {
+			if s[j] >= 0x40 && s[j] <= 0x7e {
+				return s[:j+1]
+			}
+		}
+	case ']':
+		// OSC, ends with ST (ESC \) or BEL
+		for j := 2; j < len(s); j++ {
+			if s[j] == '\x07' {
+				return s[:j+1]
+			}
+			if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+				return s[:j+2]
+			}
+		}
+	default:
+		return s[:2]
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestRenderWrapsVisibleText(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	config := DefaultConfig()
+	config.Layout = LayoutConfig{MaxWidth: 40, Padding: 2, Align: "left"}
+	raw := "Some text with [a link](https://example.com/a/rather/long/path/that/takes/room) " +
+		"in the middle of a paragraph that is long enough to wrap several times.\n"
+	m := model{raw: raw, width: 100, config: config}
+
+	var words []string
+	for _, line := range strings.Split(string(m.render()), "\n") {
+		plain := stripANSI(line)
+		if width := runewidth.StringWidth(plain); width > 42 {
+			t.Errorf("Expected lines of at most 42 columns, got %d: %q", width, plain)
+		}
+		words = append(words, strings.Fields(plain)...)
+	}
+
+	expected := "Some text with a link in the middle of a paragraph that is long enough to wrap several times."
+	if got := strings.Join(words, " "); got != expected {
+		t.Errorf("Expected text %q, got %q", expected, got)
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	link := "\x1b]8;;https://example.com\x1b\\"
+	tests := []struct {
+		name     string
+		line     string
+		wrap     lineWrap
+		width    int
+		expected string
+	}{
+		{
+			name:     "break at spaces",
+			line:     "  one two three four",
+			wrap:     lineWrap{prose: true, bars: 2, hang: 2},
+			width:    11,
+			expected: "  one two\n  three\n  four",
+		},
+		{
+			name:     "hanging list item",
+			line:     "  • one two three",
+			wrap:     lineWrap{prose: true, bars: 2, hang: 4},
+			width:    12,
+			expected: "  • one two\n    three",
+		},
+		{
+			name:     "long word",
+			line:     "  abcdefghij",
+			wrap:     lineWrap{prose: true, bars: 2, hang: 2},
+			width:    6,
+			expected: "  abcd\n  efgh\n  ij",
+		},
+		{
+			name:     "styles and links continue",
+			line:     "  \x1b[1m" + link + "one two\x1b]8;;\x1b\\\x1b[0m",
+			wrap:     lineWrap{prose: true, bars: 2, hang: 2},
+			width:    5,
+			expected: "  \x1b[1m" + link + "one\x1b]8;;\x1b\\\x1b[0m\n  \x1b[1m" + link + "two\x1b]8;;\x1b\\\x1b[0m",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapLine(tt.line, tt.wrap, tt.width); got != tt.expected {
+				t.Errorf("wrapLine() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRewrapQuoteWithoutColors(t *testing.T) {
+	useColorProfile(t, ProfileNoColor)
+
+	lines := "┃ one two three four\n┃ five six seven ten\nAfter the quote."
+	unwrapped, wraps := unwrapParagraphs([]byte(lines), 20)
+
+	expected := "┃ one two three four five six seven ten\nAfter the quote."
+	if string(unwrapped) != expected {
+		t.Errorf("unwrapParagraphs() = %q, expected %q", unwrapped, expected)
+	}
+	if got := string(wrapParagraphs(unwrapped, wraps, 20)); got != lines {
+		t.Errorf("wrapParagraphs() = %q, expected %q", got, lines)
+	}
+}